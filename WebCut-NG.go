@@ -4,68 +4,78 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/ioutil"
-	"net"
 	"net/http"
+	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/chromedp/chromedp"
-	"github.com/jchv/go-webview2"
 )
 
 var (
-	currentScreenshot []byte
-	batchScreenshots  = make(map[string][]byte)
-	serverAddr        string
-	batchMutex        sync.Mutex
-	urlList           []string
-	urlListMutex      sync.Mutex
+	batchScreenshots = make(map[string][]byte)
+	batchResults     = make(map[string]*CaptureResult)
+	batchResultsByID = make(map[string]*CaptureResult)
+	batchRunID       string
+	serverAddr       string
+	batchMutex       sync.Mutex
+	urlList          []string
+	urlListMutex     sync.Mutex
 )
 
 func main() {
 	// 设置DPI感知
 	runtime.LockOSThread()
 
-	// 创建并启动本地HTTP服务器
-	serverAddr = startServer()
-
-	// 创建WebView窗口（禁用调试模式）
-	w := webview2.New(false)
-	defer w.Destroy()
-
-	// 设置窗口标题
-	w.SetTitle("WebCut-网页快照")
-
-	// 加载本地服务器的HTML页面
-	w.Navigate(fmt.Sprintf("http://%s", serverAddr))
+	// serve 子命令作为共享的截图服务器运行，不打开窗口
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := runServe(os.Args[2:]); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// 运行WebView主循环
-	w.Run()
+	// 打开桌面界面：Windows默认使用WebView2窗口显示本地页面，其他平台（或以 fyne 标签构建时）使用Fyne原生界面
+	runDesktop()
 }
 
-// 启动本地HTTP服务器
+// 界面页面的模板数据
 type PageData struct {
 	ServerAddr string
+	CSRFToken  string // 界面发出修改请求时带上的CSRF令牌
 }
 
-// 启动本地HTTP服务器
-func startServer() string {
-	// 初始化浏览器池
-	initBrowserPool()
+// 启动HTTP服务器，返回服务器和访问地址
+func startServer(cfg ServerConfig) (*http.Server, string, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, "", err
+	}
+	// 所有接口注册在独立的ServeMux上，其他包在默认ServeMux上注册的处理器（如pprof）不会对外提供
+	mux := http.NewServeMux()
+	server, err := newHTTPServer(cfg, mux)
+	if err != nil {
+		return nil, "", err
+	}
 
 	// 创建一个监听器
-	listener, err := net.Listen("tcp", "127.0.0.1:1427")
+	listener, err := cfg.listen()
 	if err != nil {
-		panic(err)
+		return nil, "", err
 	}
 
 	// 获取分配的地址和端口
-	addr := "127.0.0.1:1427"
+	addr := cfg.baseURL(listener.Addr())
+
+	// 初始化浏览器池
+	initBrowserPool()
 
 	// 定义HTML模板
 	htmlTemplate := `
@@ -136,9 +146,268 @@ func startServer() string {
 		.url-item:last-child {
 			border-bottom: none;
 		}
+		.recapture-btn {
+			margin-top: 8px;
+			padding: 4px 10px;
+			font-size: 12px;
+			align-self: flex-start;
+		}
+		.url-action {
+			float: right;
+			margin-left: 6px;
+			padding: 2px 8px;
+			font-size: 12px;
+		}
+		.job-controls {
+			margin-top: 8px;
+		}
+		.job-controls button {
+			margin-right: 6px;
+		}
 		#fileInput {
 			display: none;
 		}
+		.capture-options {
+			margin: 10px 0;
+			padding: 10px;
+			border: 1px solid #ddd;
+			border-radius: 4px;
+		}
+		.capture-options label {
+			margin-right: 12px;
+			font-size: 14px;
+		}
+		.capture-options input[type="text"], .capture-options input[type="number"], .capture-options input[type="password"] {
+			width: auto;
+			padding: 6px;
+			margin: 0 6px 0 0;
+		}
+		.tech-tag {
+			display: inline-block;
+			font-size: 11px;
+			background-color: #eaf2fb;
+			color: #1f5f99;
+			border-radius: 3px;
+			padding: 1px 6px;
+			margin: 0 4px 4px 0;
+		}
+		.category-badge {
+			display: inline-block;
+			font-size: 11px;
+			color: white;
+			background-color: #888;
+			border-radius: 3px;
+			padding: 1px 6px;
+			margin: 0 0 4px 0;
+			align-self: flex-start;
+		}
+		.category-login { background-color: #d35400; }
+		.category-waf { background-color: #8e44ad; }
+		.category-error { background-color: #c0392b; }
+		.category-default-page, .category-directory-listing { background-color: #2980b9; }
+		.favicon-line {
+			font-size: 11px;
+			color: #666;
+			margin: 4px 0 0 0;
+		}
+		.favicon-line img {
+			width: 16px;
+			height: 16px;
+			vertical-align: middle;
+			margin-right: 4px;
+		}
+		.result-note {
+			font-size: 12px;
+			color: #b36b00;
+			margin: 4px 0 0 0;
+		}
+		.capture-failed {
+			display: flex;
+			flex-direction: column;
+			align-items: center;
+			justify-content: center;
+			gap: 6px;
+			min-height: 160px;
+			margin-bottom: 10px;
+			padding: 10px;
+			background-color: #f5f5f5;
+			border-radius: 4px;
+			color: #666;
+			font-size: 12px;
+			text-align: center;
+		}
+		.capture-failed strong {
+			font-size: 18px;
+			color: #c0392b;
+		}
+		.capture-failed-url {
+			word-break: break-all;
+		}
+		.result-error {
+			color: #c0392b;
+			word-break: break-all;
+		}
+		.screenshot-item.selected {
+			outline: 3px solid #3498db;
+			outline-offset: 1px;
+		}
+		.screenshot-item.triage-interesting { border-top: 4px solid #27ae60 !important; }
+		.screenshot-item.triage-not-interesting { opacity: 0.6; }
+		.screenshot-item.triage-follow-up { border-top: 4px solid #e67e22 !important; }
+		.triage-bar {
+			margin-top: 8px;
+		}
+		.triage-bar button {
+			padding: 2px 8px;
+			margin: 0 4px 4px 0;
+			font-size: 12px;
+			background-color: #ecf0f1;
+			color: #333;
+		}
+		.triage-bar button.active {
+			background-color: #3498db;
+			color: white;
+		}
+		.triage-bar input[type="text"], .triage-bar textarea {
+			width: 100%;
+			padding: 4px;
+			margin: 0 0 4px 0;
+			box-sizing: border-box;
+			border: 1px solid #ddd;
+			border-radius: 4px;
+			font-size: 12px;
+			font-family: inherit;
+		}
+		.triage-hint {
+			font-size: 12px;
+			color: #888;
+			margin: 0 0 10px 0;
+		}
+		.search-box {
+			margin-top: 20px;
+		}
+		.search-box input[type="text"] {
+			width: 60%;
+			margin: 0 6px 0 0;
+		}
+		.search-box button {
+			padding: 10px 18px;
+		}
+		.search-result {
+			display: flex;
+			gap: 10px;
+			padding: 8px 0;
+			border-bottom: 1px solid #eee;
+			font-size: 13px;
+		}
+		.search-result img {
+			width: 120px;
+			height: auto;
+			align-self: flex-start;
+			border-radius: 4px;
+			cursor: pointer;
+		}
+		.search-result p {
+			margin: 0 0 4px 0;
+			word-break: break-all;
+		}
+		.search-result .search-meta, .compare-item .search-meta {
+			font-size: 12px;
+			color: #666;
+		}
+		.search-result mark, .search-snippet mark {
+			background-color: #f9e79f;
+		}
+		.screenshot-item.search-hit {
+			box-shadow: 0 0 0 3px #f1c40f !important;
+		}
+		.compare-box {
+			margin-top: 20px;
+			font-size: 14px;
+		}
+		.compare-box select, .compare-box input[type="number"] {
+			margin: 0 10px 0 4px;
+		}
+		.compare-box input[type="number"] {
+			width: 60px;
+		}
+		.compare-item {
+			padding: 10px 0;
+			border-bottom: 1px solid #eee;
+			font-size: 13px;
+		}
+		.compare-item p {
+			margin: 0 0 4px 0;
+			word-break: break-all;
+		}
+		.compare-images {
+			display: flex;
+			gap: 10px;
+			margin: 6px 0;
+		}
+		.compare-images figure {
+			margin: 0;
+			width: 32%;
+		}
+		.compare-images img {
+			width: 100%;
+			height: auto;
+			border: 1px solid #ddd;
+			border-radius: 4px;
+			cursor: pointer;
+		}
+		.compare-images figcaption {
+			font-size: 12px;
+			color: #666;
+		}
+		.compare-badge {
+			display: inline-block;
+			padding: 1px 6px;
+			margin-right: 6px;
+			border-radius: 3px;
+			color: #fff;
+			font-size: 12px;
+		}
+		.compare-new { background-color: #27ae60; }
+		.compare-removed { background-color: #7f8c8d; }
+		.compare-changed { background-color: #e74c3c; }
+		.compare-unchanged { background-color: #3498db; }
+		.compare-changes {
+			margin: 4px 0 0 0;
+			padding-left: 18px;
+			color: #555;
+		}
+		.lightbox {
+			display: none;
+			position: fixed;
+			top: 0;
+			left: 0;
+			right: 0;
+			bottom: 0;
+			z-index: 1000;
+			background-color: rgba(0, 0, 0, 0.85);
+			flex-direction: column;
+			align-items: center;
+			justify-content: center;
+			padding: 20px;
+			box-sizing: border-box;
+		}
+		.lightbox img {
+			max-width: 100%;
+			max-height: calc(100vh - 120px);
+			background-color: white;
+		}
+		.lightbox-caption {
+			color: white;
+			font-size: 14px;
+			margin-top: 10px;
+			text-align: center;
+			word-break: break-all;
+		}
+		.lightbox-caption a {
+			color: #8ecbf5;
+			margin-left: 8px;
+		}
 		input[type="text"] {
 			width: 100%;
 			padding: 12px;
@@ -149,12 +418,16 @@ func startServer() string {
 		}
 	</style>
 	<script>
+	// 修改状态的请求需带上CSRF令牌
+	var csrfToken = {{.CSRFToken}};
+
 	document.addEventListener('DOMContentLoaded', function() {
 		var captureBtn = document.getElementById('captureBtn');
 		var urlInput = document.getElementById('urlInput');
 		var message = document.getElementById('message');
 		var imgContainer = document.getElementById('imgContainer');
-		var screenshotImg = document.getElementById('screenshotImg');
+		var singleStatus = document.getElementById('singleStatus');
+		var singleResult = document.getElementById('singleResult');
 		var loadListBtn = document.getElementById('loadListBtn');
 		var fileInput = document.getElementById('fileInput');
 		var urlListElement = document.getElementById('urlList');
@@ -164,6 +437,59 @@ func startServer() string {
 		var progressBar = document.getElementById('progressBar');
 		var batchResultsContainer = document.getElementById('batchResults');
 		var screenshotsGrid = document.getElementById('screenshotsGrid');
+		var fullPageInput = document.getElementById('fullPageInput');
+		var selectorInput = document.getElementById('selectorInput');
+		var selectorTypeInput = document.getElementById('selectorTypeInput');
+		var savePdfInput = document.getElementById('savePdfInput');
+		var saveMhtmlInput = document.getElementById('saveMhtmlInput');
+		var saveHarInput = document.getElementById('saveHarInput');
+		var harBodiesInput = document.getElementById('harBodiesInput');
+		var discoverSansInput = document.getElementById('discoverSansInput');
+		var retryBlankInput = document.getElementById('retryBlankInput');
+		var retriesInput = document.getElementById('retriesInput');
+		var retryDelayInput = document.getElementById('retryDelayInput');
+		var viewportWidthInput = document.getElementById('viewportWidthInput');
+		var viewportHeightInput = document.getElementById('viewportHeightInput');
+		var waitSelectorInput = document.getElementById('waitSelectorInput');
+		var waitDelayInput = document.getElementById('waitDelayInput');
+		var authUserInput = document.getElementById('authUserInput');
+		var authPasswordInput = document.getElementById('authPasswordInput');
+		var authCookieInput = document.getElementById('authCookieInput');
+		var finalPassInput = document.getElementById('finalPassInput');
+		var scopeInput = document.getElementById('scopeInput');
+		var techFilter = document.getElementById('techFilter');
+		var faviconFilter = document.getElementById('faviconFilter');
+		var categoryFilter = document.getElementById('categoryFilter');
+		var triageFilter = document.getElementById('triageFilter');
+		var tagFilter = document.getElementById('tagFilter');
+		var statusFilter = document.getElementById('statusFilter');
+		var exportJsonLink = document.getElementById('exportJsonLink');
+		var exportCsvLink = document.getElementById('exportCsvLink');
+		var searchInput = document.getElementById('searchInput');
+		var searchBtn = document.getElementById('searchBtn');
+		var searchOnlyHits = document.getElementById('searchOnlyHits');
+		var searchResults = document.getElementById('searchResults');
+		var baseRunSelect = document.getElementById('baseRunSelect');
+		var targetRunSelect = document.getElementById('targetRunSelect');
+		var compareThreshold = document.getElementById('compareThreshold');
+		var compareFilter = document.getElementById('compareFilter');
+		var compareBtn = document.getElementById('compareBtn');
+		var compareResults = document.getElementById('compareResults');
+		var lightbox = document.getElementById('lightbox');
+		var lightboxImg = document.getElementById('lightboxImg');
+		var lightboxCaption = document.getElementById('lightboxCaption');
+		var clusterToggle = document.getElementById('clusterToggle');
+		var clusterDistance = document.getElementById('clusterDistance');
+		var clustersView = document.getElementById('clustersView');
+		var reportLink = document.getElementById('reportLink');
+		var jobControls = document.getElementById('jobControls');
+		var pauseJobBtn = document.getElementById('pauseJobBtn');
+		var cancelJobBtn = document.getElementById('cancelJobBtn');
+		var concurrencyInput = document.getElementById('concurrencyInput');
+		var concurrencyBtn = document.getElementById('concurrencyBtn');
+		var clipInputs = ['clipX', 'clipY', 'clipWidth', 'clipHeight'].map(function(id) {
+			return document.getElementById(id);
+		});
 
 		// 页面加载完成后，自动获取已加载的URL列表
 		fetch('/get-urls', {
@@ -188,12 +514,83 @@ func startServer() string {
 			}, 3000);
 		}
 
-		function showScreenshot(base64Image) {
-			screenshotImg.src = 'data:image/png;base64,' + base64Image;
+		// 截图单个URL，结果保存为新的运行，返回 {runId, report, result}
+		function captureSingle(url) {
+			return fetch('/api/v1/captures', {
+				method: 'POST',
+				headers: {'Content-Type': 'application/json', 'X-CSRF-Token': csrfToken},
+				body: JSON.stringify({url: url, options: getCaptureOptions()})
+			}).then(function(response) {
+				return response.json();
+			}).then(function(data) {
+				if (data.error) {
+					throw new Error(data.error.message);
+				}
+				return data;
+			});
+		}
+
+		// 单个截图：截图过程中显示状态，完成后在结果区显示与网格相同的结果卡片
+		function captureUrlInput() {
+			var url = urlInput.value.trim();
+			if (url === '') {
+				showMessage('请输入要截图的网址', true);
+				return;
+			}
+			captureBtn.disabled = true;
 			imgContainer.style.display = 'block';
+			singleResult.innerHTML = '';
+			singleStatus.textContent = '正在截图 ' + url + ' ...';
+			captureSingle(url).then(function(data) {
+				singleStatus.textContent = '';
+				var link = document.createElement('a');
+				link.href = data.report;
+				link.target = '_blank';
+				link.textContent = '运行报告 ' + data.runId;
+				singleStatus.appendChild(link);
+				singleResult.appendChild(createResultItem(data.result.url, data.result, data.runId));
+				if (data.result.error) {
+					showMessage(data.result.error, true);
+				}
+			}).catch(function(error) {
+				singleStatus.textContent = '';
+				showMessage('截图失败: ' + error.message, true);
+			}).then(function() {
+				captureBtn.disabled = false;
+			});
+		}
+
+		captureBtn.addEventListener('click', captureUrlInput);
+		urlInput.addEventListener('keydown', function(e) {
+			if (e.key === 'Enter') {
+				captureUrlInput();
+			}
+		});
+
+		// 重新截图结果卡片对应的URL，完成后替换该卡片
+		function recaptureItem(item, url, button) {
+			button.disabled = true;
+			button.textContent = '截图中...';
+			item.style.opacity = '0.5';
+			captureSingle(url).then(function(data) {
+				var replacement = createResultItem(data.result.url, data.result, data.runId);
+				item.parentNode.replaceChild(replacement, item);
+				if (selectedItem === item) selectItem(replacement);
+				updateTagFilter();
+				showMessage(data.result.error ? data.result.error : '已重新截图 ' + data.result.url, !!data.result.error);
+			}).catch(function(error) {
+				button.disabled = false;
+				button.textContent = '重新截图';
+				item.style.opacity = '1';
+				showMessage('重新截图失败: ' + error.message, true);
+			});
 		}
 
+		// 当前的URL列表，任务运行时每项显示控制按钮
+		var currentUrls = [];
+
 		function updateUrlList(urls) {
+			currentUrls = urls || [];
 			urlListElement.innerHTML = '';
 			if (urls && urls.length > 0) {
 				urls.forEach(function(url, index) {
@@ -204,6 +601,18 @@ func startServer() string {
 						console.log('点击URL项：', url);
 						// 不再需要设置输入框值
 					};
+					if (currentJobId) {
+						[['prioritize', '优先'], ['skip', '跳过'], ['cancel', '取消']].forEach(function(action) {
+							var button = document.createElement('button');
+							button.className = 'url-action';
+							button.textContent = action[1];
+							button.onclick = function(e) {
+								e.stopPropagation();
+								sendJobCommand({action: action[0], url: url});
+							};
+							div.appendChild(button);
+						});
+					}
 					urlListElement.appendChild(div);
 				});
 			} else {
@@ -214,180 +623,1095 @@ func startServer() string {
 			}
 		}
 
-		// 显示单个已完成的截图
-		function showCompletedScreenshot(url) {
-			// 规范化URL格式，与后端完全一致
-			let normalizedUrl = url;
-			// 先检测是否是163.com，保留原始URL进行检测
-			if (url.toLowerCase().includes('163.com')) {
-				normalizedUrl = 'https://www.163.com';
+		// 读取截图选项：整页、元素选择器、裁剪区域
+		function getCaptureOptions() {
+			var options = {fullPage: fullPageInput.checked};
+			if (selectorInput.value.trim() !== '') {
+				options.selector = selectorInput.value.trim();
+				options.selectorType = selectorTypeInput.value;
+			}
+			var clip = clipInputs.map(function(input) { return parseFloat(input.value); });
+			if (clip[2] > 0 && clip[3] > 0) {
+				options.clip = {x: clip[0] || 0, y: clip[1] || 0, width: clip[2], height: clip[3]};
+			}
+			options.savePdf = savePdfInput.checked;
+			options.saveMhtml = saveMhtmlInput.checked;
+			options.saveHar = saveHarInput.checked;
+			options.harBodies = harBodiesInput.checked;
+			options.discoverSans = discoverSansInput.checked;
+			options.retryBlank = retryBlankInput.checked;
+			options.retry = {finalPass: finalPassInput.checked};
+			var retries = parseInt(retriesInput.value, 10);
+			if (retries >= 0) {
+				options.retry.maxRetries = retries;
+			}
+			var retryDelay = parseFloat(retryDelayInput.value);
+			if (retryDelay > 0) {
+				options.retry.baseDelay = Math.round(retryDelay * 1000);
+			}
+			options.scope = scopeInput.value.split(/[\s,]+/).filter(function(entry) {
+				return entry !== '';
+			});
+			var viewportWidth = parseInt(viewportWidthInput.value, 10);
+			var viewportHeight = parseInt(viewportHeightInput.value, 10);
+			if (viewportWidth > 0 || viewportHeight > 0) {
+				options.viewport = {width: viewportWidth || 0, height: viewportHeight || 0};
+			}
+			options.wait = {selector: waitSelectorInput.value.trim()};
+			var waitDelay = parseFloat(waitDelayInput.value);
+			if (waitDelay > 0) {
+				options.wait.delay = Math.round(waitDelay * 1000);
+			}
+			if (authUserInput.value !== '' || authCookieInput.value.trim() !== '') {
+				options.auth = {
+					username: authUserInput.value,
+					password: authPasswordInput.value,
+					cookie: authCookieInput.value.trim()
+				};
+			}
+			return options;
+		}
+
+		// 显示证书信息和异常标记（过期、自签名、域名不匹配等）
+		function appendCertificate(container, result) {
+			if (!result || !result.certificate) return;
+			var cert = result.certificate;
+			var line = document.createElement('p');
+			line.style.fontSize = '12px';
+			line.style.margin = '4px 0 0 0';
+			line.title = '主体: ' + cert.subject + '\n颁发者: ' + cert.issuer +
+				'\n有效期: ' + cert.notBefore + ' ~ ' + cert.notAfter +
+				'\nSHA256: ' + cert.fingerprintSha256 +
+				(cert.sans ? '\nSAN: ' + cert.sans.join(', ') : '');
+			var anomalies = [];
+			if (cert.expired) anomalies.push('证书已过期');
+			if (cert.notYetValid) anomalies.push('证书尚未生效');
+			if (cert.selfSigned) anomalies.push('自签名证书');
+			else if (cert.untrusted) anomalies.push('证书链不受信任');
+			if (cert.hostnameMismatch) anomalies.push('证书域名不匹配');
+			if (anomalies.length > 0) {
+				line.textContent = '证书: ' + anomalies.join('、');
+				line.style.color = '#721c24';
 			} else {
-				// 移除末尾斜杠
-				normalizedUrl = url.endsWith('/') && url.length > 1 ? url.slice(0, -1) : url;
-				// 确保包含协议前缀
-				if (!normalizedUrl.startsWith('http://') && !normalizedUrl.startsWith('https://')) {
-					normalizedUrl = 'http://' + normalizedUrl;
+				line.textContent = '证书: 正常，剩余 ' + cert.daysLeft + ' 天';
+				line.style.color = '#155724';
+			}
+			container.appendChild(line);
+			if (result.discovered && result.discovered.length > 0) {
+				var discovered = document.createElement('p');
+				discovered.style.fontSize = '12px';
+				discovered.style.margin = '2px 0 0 0';
+				discovered.style.color = '#666';
+				discovered.textContent = '由证书发现 ' + result.discovered.length + ' 个新目标: ' + result.discovered.join(', ');
+				container.appendChild(discovered);
+			}
+		}
+
+		// 显示控制台、JS异常和失败请求的数量，点击展开详情
+		function appendPageEvents(container, result) {
+			if (!result) return;
+			var consoleList = result.console || [];
+			var exceptions = result.exceptions || [];
+			var failed = result.failedRequests || [];
+			if (consoleList.length + exceptions.length + failed.length === 0) return;
+
+			var details = document.createElement('details');
+			details.style.fontSize = '12px';
+			details.style.marginTop = '4px';
+			var summary = document.createElement('summary');
+			summary.textContent = '控制台 ' + consoleList.length + ' · 异常 ' + exceptions.length + ' · 失败请求 ' + failed.length;
+			if (exceptions.length > 0 || failed.length > 0) {
+				summary.style.color = '#721c24';
+			}
+			details.appendChild(summary);
+
+			function addSection(title, items, format) {
+				if (items.length === 0) return;
+				var heading = document.createElement('p');
+				heading.textContent = title;
+				heading.style.fontWeight = 'bold';
+				heading.style.margin = '6px 0 2px 0';
+				details.appendChild(heading);
+				items.forEach(function(item) {
+					var pre = document.createElement('pre');
+					pre.textContent = format(item);
+					pre.style.whiteSpace = 'pre-wrap';
+					pre.style.wordBreak = 'break-all';
+					pre.style.margin = '0 0 4px 0';
+					pre.style.padding = '4px';
+					pre.style.backgroundColor = '#f7f7f7';
+					details.appendChild(pre);
+				});
+			}
+			addSection('JS异常', exceptions, function(e) {
+				return e.message + (e.url ? '\n' + e.url + ':' + e.line + ':' + e.column : '') + (e.stack ? '\n' + e.stack : '');
+			});
+			addSection('失败请求', failed, function(f) {
+				return f.error + (f.blocked ? ' (' + f.blocked + ')' : '') + ' ' + f.url;
+			});
+			addSection('控制台', consoleList, function(c) {
+				return '[' + c.level + '] ' + c.text + (c.stack ? '\n' + c.stack : '');
+			});
+			container.appendChild(details);
+		}
+
+		// 在截图下方显示PDF/MHTML附件链接
+		function appendArtifactLinks(container, result, runId) {
+			if (!result || !result.artifacts || !runId) return;
+			var links = document.createElement('p');
+			links.style.margin = '4px 0 0 0';
+			links.style.fontSize = '12px';
+			[['pdf', 'PDF'], ['mhtml', 'MHTML'], ['har', 'HAR'], ['dom', 'DOM'], ['text', '文本']].forEach(function(kind) {
+				if (!result.artifacts[kind[0]]) return;
+				var a = document.createElement('a');
+				a.href = '/runs/' + runId + '/' + result.artifacts[kind[0]];
+				a.target = '_blank';
+				a.textContent = kind[1];
+				a.style.marginRight = '8px';
+				links.appendChild(a);
+			});
+			if (links.childNodes.length > 0) {
+				container.appendChild(links);
+			}
+		}
+
+		// 在截图下方显示页面标题
+		function appendResultTitle(container, result) {
+			if (!result || !result.title) return;
+			var title = document.createElement('p');
+			title.textContent = result.title;
+			title.style.fontSize = '14px';
+			title.style.margin = '0 0 4px 0';
+			container.appendChild(title);
+		}
+
+		// 显示指纹识别出的产品标签，并记录到容器上供筛选使用
+		function appendTechnologies(container, result) {
+			var names = [];
+			if (result && result.technologies) {
+				var tags = document.createElement('p');
+				tags.style.margin = '4px 0 0 0';
+				result.technologies.forEach(function(tech) {
+					names.push(tech.name);
+					var tag = document.createElement('span');
+					tag.className = 'tech-tag';
+					tag.textContent = tech.version ? tech.name + ' ' + tech.version : tech.name;
+					tag.title = tech.category || '';
+					tags.appendChild(tag);
+				});
+				if (names.length > 0) {
+					container.appendChild(tags);
+				}
+			}
+			container.dataset.techs = JSON.stringify(names);
+			updateTechFilter(names);
+			applyFilters(container);
+		}
+
+		// 显示页面分类标签，并记录到容器上供筛选使用
+		function appendCategory(container, result) {
+			if (!result || !result.category) return;
+			var badge = document.createElement('span');
+			badge.className = 'category-badge category-' + result.category;
+			badge.textContent = result.categoryLabel || result.category;
+			container.appendChild(badge);
+			container.dataset.category = result.category;
+			for (var i = 0; i < categoryFilter.options.length; i++) {
+				if (categoryFilter.options[i].value === result.category) {
+					applyFilters(container);
+					return;
+				}
+			}
+			var option = document.createElement('option');
+			option.value = result.category;
+			option.textContent = result.categoryLabel || result.category;
+			categoryFilter.appendChild(option);
+			applyFilters(container);
+		}
+
+		// 显示favicon及其mmh3哈希，并记录到容器上供按图标分组筛选
+		function appendFavicon(container, result, runId) {
+			if (!result || !result.faviconMmh3) return;
+			var line = document.createElement('p');
+			line.className = 'favicon-line';
+			var iconPath = result.artifacts && result.artifacts.favicon;
+			if (iconPath && runId) {
+				var icon = document.createElement('img');
+				icon.src = '/runs/' + runId + '/' + iconPath;
+				line.appendChild(icon);
+			}
+			line.appendChild(document.createTextNode('mmh3: ' + result.faviconMmh3));
+			line.title = 'md5: ' + result.faviconMd5 + (result.faviconUrl ? '\n' + result.faviconUrl : '');
+			container.appendChild(line);
+			container.dataset.favicon = result.faviconMmh3;
+			updateFaviconFilter(container);
+			applyFilters(container);
+		}
+
+		// 按favicon哈希统计截图数量，刷新分组下拉框（数量多的图标排在前面）
+		// pending 为尚未加入网格的新截图容器
+		function updateFaviconFilter(pending) {
+			var counts = {};
+			var items = Array.from(screenshotsGrid.querySelectorAll('.screenshot-item'));
+			if (pending && items.indexOf(pending) < 0) items.push(pending);
+			items.forEach(function(item) {
+				var hash = item.dataset.favicon;
+				if (hash) counts[hash] = (counts[hash] || 0) + 1;
+			});
+			var selected = faviconFilter.value;
+			while (faviconFilter.options.length > 1) {
+				faviconFilter.remove(1);
+			}
+			Object.keys(counts).sort(function(a, b) { return counts[b] - counts[a]; }).forEach(function(hash) {
+				var option = document.createElement('option');
+				option.value = hash;
+				option.textContent = hash + ' (' + counts[hash] + ')';
+				faviconFilter.appendChild(option);
+			});
+			faviconFilter.value = selected;
+		}
+
+		// 将新出现的产品加入筛选下拉框
+		function updateTechFilter(names) {
+			names.forEach(function(name) {
+				for (var i = 0; i < techFilter.options.length; i++) {
+					if (techFilter.options[i].value === name) return;
+				}
+				var option = document.createElement('option');
+				option.value = name;
+				option.textContent = name;
+				techFilter.appendChild(option);
+			});
+		}
+
+		// 按页面分类、产品、favicon、审阅状态、标签和截图状态筛选截图，未选择时显示全部
+		function applyFilters(container) {
+			var selected = techFilter.value;
+			var names = JSON.parse(container.dataset.techs || '[]');
+			var icon = faviconFilter.value;
+			var category = categoryFilter.value;
+			var tag = tagFilter.value;
+			var tags = JSON.parse(container.dataset.tags || '[]').map(function(t) { return t.toLowerCase(); });
+			var visible = (!selected || names.indexOf(selected) >= 0) && (!icon || container.dataset.favicon === icon) &&
+				(!category || container.dataset.category === category) &&
+				(!triageFilter.value || (container.dataset.triage || 'unreviewed') === triageFilter.value) &&
+				(!tag || tags.indexOf(tag.toLowerCase()) >= 0) &&
+				(!statusFilter.value || container.dataset.status === statusFilter.value) &&
+				(!searchHitIds || !searchOnlyHits.checked || !!searchHitIds[container.dataset.resultId]);
+			container.style.display = visible ? 'flex' : 'none';
+		}
+
+		function applyAllFilters() {
+			screenshotsGrid.querySelectorAll('.screenshot-item').forEach(applyFilters);
+			updateExportLinks();
+		}
+
+		// 导出链接带上审阅状态、标签、分类和截图状态筛选条件，只导出筛选出的结果
+		function updateExportLinks() {
+			var params = [];
+			[['triage', triageFilter], ['tag', tagFilter], ['category', categoryFilter], ['status', statusFilter]].forEach(function(pair) {
+				if (pair[1].value) params.push(pair[0] + '=' + encodeURIComponent(pair[1].value));
+			});
+			var query = params.length > 0 ? '&' + params.join('&') : '';
+			exportJsonLink.href = '/export-results?format=json' + query;
+			exportCsvLink.href = '/export-results?format=csv' + query;
+		}
+
+		techFilter.addEventListener('change', applyAllFilters);
+		faviconFilter.addEventListener('change', applyAllFilters);
+		categoryFilter.addEventListener('change', applyAllFilters);
+		triageFilter.addEventListener('change', applyAllFilters);
+		tagFilter.addEventListener('change', applyAllFilters);
+		statusFilter.addEventListener('change', applyAllFilters);
+
+		// 全文检索在网格中命中的结果ID，未检索时为 null
+		var searchHitIds = null;
+		var searchFieldLabels = {url: '网址', title: '标题', header: '响应头', tech: '产品', text: '页面文本'};
+
+		// 检索所有运行的截图结果，列出命中结果并在网格中标出当前显示的命中项
+		function runSearch() {
+			var query = searchInput.value.trim();
+			if (query === '') {
+				searchHitIds = null;
+				searchResults.innerHTML = '';
+				markSearchHits();
+				return;
+			}
+			searchBtn.disabled = true;
+			fetch('/api/v1/search?limit=100&q=' + encodeURIComponent(query)).then(function(response) {
+				return response.json();
+			}).then(function(data) {
+				if (data.error) {
+					throw new Error(data.error.message);
+				}
+				renderSearchResults(data);
+				return highlightGallery(query);
+			}).catch(function(error) {
+				showMessage('检索失败: ' + error.message, true);
+			}).then(function() {
+				searchBtn.disabled = false;
+			});
+		}
+
+		// 只检索网格中截图所属的运行，取得全部命中的结果ID
+		function highlightGallery(query) {
+			var runs = {};
+			screenshotsGrid.querySelectorAll('.screenshot-item').forEach(function(item) {
+				var id = item.dataset.resultId;
+				if (id) runs[id.substring(0, id.lastIndexOf('-'))] = true;
+			});
+			searchHitIds = {};
+			if (Object.keys(runs).length === 0) {
+				markSearchHits();
+				return;
+			}
+			return fetch('/api/v1/search?snippet=0&limit=1000&q=' + encodeURIComponent(query) +
+				'&run=' + encodeURIComponent(Object.keys(runs).join(','))).then(function(response) {
+				return response.json();
+			}).then(function(data) {
+				(data.hits || []).forEach(function(hit) {
+					searchHitIds[hit.id] = true;
+				});
+				markSearchHits();
+			});
+		}
+
+		function markSearchHits() {
+			screenshotsGrid.querySelectorAll('.screenshot-item').forEach(function(item) {
+				item.classList.toggle('search-hit', !!(searchHitIds && searchHitIds[item.dataset.resultId]));
+			});
+			applyAllFilters();
+		}
+
+		// 查找网格中某个结果的卡片
+		function findResultItem(id) {
+			var items = screenshotsGrid.querySelectorAll('.screenshot-item');
+			for (var i = 0; i < items.length; i++) {
+				if (items[i].dataset.resultId === id) return items[i];
+			}
+			return null;
+		}
+
+		// 检索结果列表：缩略图、标题、网址、所属运行、命中字段和高亮的摘要
+		function renderSearchResults(data) {
+			searchResults.innerHTML = '';
+			var summary = document.createElement('p');
+			summary.textContent = data.total === 0 ? '没有匹配的结果' :
+				'共 ' + data.total + ' 个匹配的结果' + (data.total > data.hits.length ? '，显示前 ' + data.hits.length + ' 个' : '');
+			searchResults.appendChild(summary);
+			data.hits.forEach(function(hit) {
+				var row = document.createElement('div');
+				row.className = 'search-result';
+				if (hit.thumb) {
+					var img = document.createElement('img');
+					img.src = hit.thumb;
+					img.loading = 'lazy';
+					img.title = '点击查看原图';
+					img.addEventListener('click', function() {
+						window.open(hit.image, '_blank');
+					});
+					row.appendChild(img);
+				}
+				var info = document.createElement('div');
+				if (hit.title) {
+					var title = document.createElement('p');
+					title.textContent = hit.title;
+					info.appendChild(title);
+				}
+				var url = document.createElement('p');
+				url.textContent = hit.url;
+				info.appendChild(url);
+
+				var meta = document.createElement('p');
+				meta.className = 'search-meta';
+				meta.appendChild(document.createTextNode('命中: ' + hit.fields.map(function(field) {
+					return searchFieldLabels[field] || field;
+				}).join('、') + ' · 运行 '));
+				var report = document.createElement('a');
+				report.href = '/runs/' + hit.runId + '/report.html';
+				report.target = '_blank';
+				report.textContent = hit.runId;
+				meta.appendChild(report);
+				var item = findResultItem(hit.id);
+				if (item) {
+					var locate = document.createElement('a');
+					locate.href = '#';
+					locate.textContent = '在网格中查看';
+					locate.style.marginLeft = '8px';
+					locate.addEventListener('click', function(e) {
+						e.preventDefault();
+						selectItem(item);
+					});
+					meta.appendChild(locate);
+				}
+				info.appendChild(meta);
+
+				if (hit.snippet) {
+					var snippet = document.createElement('p');
+					snippet.className = 'search-snippet';
+					hit.snippet.forEach(function(part) {
+						if (part.match) {
+							var mark = document.createElement('mark');
+							mark.textContent = part.text;
+							snippet.appendChild(mark);
+						} else {
+							snippet.appendChild(document.createTextNode(part.text));
+						}
+					});
+					info.appendChild(snippet);
+				}
+				row.appendChild(info);
+				searchResults.appendChild(row);
+			});
+		}
+
+		searchBtn.addEventListener('click', runSearch);
+		searchInput.addEventListener('keydown', function(e) {
+			if (e.key === 'Enter') {
+				runSearch();
+			}
+		});
+		searchOnlyHits.addEventListener('change', applyAllFilters);
+
+		// 运行对比：对比状态和页面信息字段的显示名称
+		var compareLabels = {'new': '新增', removed: '移除', changed: '变化', unchanged: '未变'};
+		var compareFieldLabels = {title: '标题', status: '状态', technologies: '产品', certificate: '证书'};
+
+		// 加载已完成的运行，默认对比最新的两次运行
+		function loadRuns() {
+			fetch('/api/v1/runs').then(function(response) {
+				return response.json();
+			}).then(function(data) {
+				var runs = data.runs || [];
+				var baseValue = baseRunSelect.value, targetValue = targetRunSelect.value;
+				[baseRunSelect, targetRunSelect].forEach(function(select) {
+					select.innerHTML = '';
+					runs.forEach(function(run) {
+						var option = document.createElement('option');
+						option.value = run.id;
+						option.textContent = run.id;
+						select.appendChild(option);
+					});
+				});
+				if (runs.length > 1) {
+					targetRunSelect.value = targetValue || runs[0].id;
+					baseRunSelect.value = baseValue || runs[1].id;
+				}
+				compareBtn.disabled = runs.length < 2;
+			}).catch(function(error) {
+				console.log('获取运行列表失败: ' + error.message);
+			});
+		}
+
+		function runCompare() {
+			if (baseRunSelect.value === targetRunSelect.value) {
+				showMessage('请选择两个不同的运行进行对比', true);
+				return;
+			}
+			compareBtn.disabled = true;
+			compareResults.innerHTML = '<p>正在对比...</p>';
+			fetch('/api/v1/compare?base=' + encodeURIComponent(baseRunSelect.value) +
+				'&target=' + encodeURIComponent(targetRunSelect.value) +
+				'&threshold=' + encodeURIComponent(compareThreshold.value || '1')).then(function(response) {
+				return response.json();
+			}).then(function(data) {
+				if (data.error) {
+					throw new Error(data.error.message);
+				}
+				renderComparison(data);
+			}).catch(function(error) {
+				compareResults.innerHTML = '';
+				showMessage('对比失败: ' + error.message, true);
+			}).then(function() {
+				compareBtn.disabled = false;
+			});
+		}
+
+		function compareFigure(caption, src, full) {
+			var figure = document.createElement('figure');
+			var img = document.createElement('img');
+			img.src = src;
+			img.loading = 'lazy';
+			img.title = '点击查看原图';
+			img.addEventListener('click', function() {
+				window.open(full || src, '_blank');
+			});
+			figure.appendChild(img);
+			var figcaption = document.createElement('figcaption');
+			figcaption.textContent = caption;
+			figure.appendChild(figcaption);
+			return figure;
+		}
+
+		// 对比结果：各状态数量，每个目标的前后截图、变化叠加图和页面信息变化
+		function renderComparison(data) {
+			compareResults.innerHTML = '';
+			var summary = document.createElement('p');
+			summary.textContent = data.base + ' → ' + data.target + '：' + ['new', 'removed', 'changed', 'unchanged'].map(function(status) {
+				return compareLabels[status] + ' ' + data.counts[status];
+			}).join(' · ');
+			compareResults.appendChild(summary);
+			data.targets.forEach(function(diff) {
+				var item = document.createElement('div');
+				item.className = 'compare-item';
+				item.dataset.status = diff.status;
+
+				var head = document.createElement('p');
+				var badge = document.createElement('span');
+				badge.className = 'compare-badge compare-' + diff.status;
+				badge.textContent = compareLabels[diff.status];
+				head.appendChild(badge);
+				head.appendChild(document.createTextNode(diff.url));
+				item.appendChild(head);
+
+				var images = document.createElement('div');
+				images.className = 'compare-images';
+				if (diff.base && diff.base.thumb) {
+					images.appendChild(compareFigure('之前 ' + data.base, diff.base.thumb, diff.base.image));
+				}
+				if (diff.target && diff.target.thumb) {
+					images.appendChild(compareFigure('之后 ' + data.target, diff.target.thumb, diff.target.image));
+				}
+				if (diff.overlay) {
+					images.appendChild(compareFigure('变化区域（' + (diff.regions ? diff.regions.length : 0) + ' 处）', diff.overlay));
+				}
+				if (images.children.length > 0) {
+					item.appendChild(images);
+				}
+
+				if (diff.base && diff.target) {
+					var meta = document.createElement('p');
+					meta.className = 'search-meta';
+					meta.textContent = '变化像素 ' + diff.pixelChange + '%' +
+						(diff.perceptualDistance >= 0 ? ' · 外观距离 ' + diff.perceptualDistance : '') +
+						(diff.visualChanged ? ' · 外观有变化' : '');
+					item.appendChild(meta);
+				}
+				if (diff.changes) {
+					var list = document.createElement('ul');
+					list.className = 'compare-changes';
+					diff.changes.forEach(function(change) {
+						var li = document.createElement('li');
+						var text = (compareFieldLabels[change.field] || change.field) + ': ';
+						if (change.added || change.removed) {
+							text += (change.added ? '新增 ' + change.added.join(', ') : '') +
+								(change.added && change.removed ? '；' : '') +
+								(change.removed ? '消失 ' + change.removed.join(', ') : '');
+						} else {
+							text += (change.before || '（无）') + ' → ' + (change.after || '（无）');
+						}
+						li.textContent = text;
+						list.appendChild(li);
+					});
+					item.appendChild(list);
+				}
+				compareResults.appendChild(item);
+			});
+			applyCompareFilter();
+		}
+
+		// 默认只显示新增、移除和变化的目标
+		function applyCompareFilter() {
+			var filter = compareFilter.value;
+			compareResults.querySelectorAll('.compare-item').forEach(function(item) {
+				var status = item.dataset.status;
+				item.style.display = (filter === 'all' || filter === status || (filter === '' && status !== 'unchanged')) ? '' : 'none';
+			});
+		}
+
+		compareBtn.addEventListener('click', runCompare);
+		compareFilter.addEventListener('change', applyCompareFilter);
+		loadRuns();
+
+		// 审阅状态及显示名称，数字键 1-3 标记，0 恢复为未审阅
+		var triageStates = [
+			{value: 'unreviewed', label: '未审阅', key: '0'},
+			{value: 'interesting', label: '有价值', key: '1'},
+			{value: 'not-interesting', label: '无价值', key: '2'},
+			{value: 'follow-up', label: '待跟进', key: '3'}
+		];
+
+		function triageLabel(state) {
+			for (var i = 0; i < triageStates.length; i++) {
+				if (triageStates[i].value === state) return triageStates[i].label;
+			}
+			return state;
+		}
+
+		// 统计所有截图的标签，刷新标签筛选下拉框，pending 为尚未加入网格的新截图容器
+		function updateTagFilter(pending) {
+			var seen = {};
+			var tags = [];
+			var items = Array.from(screenshotsGrid.querySelectorAll('.screenshot-item'));
+			if (pending && items.indexOf(pending) < 0) items.push(pending);
+			items.forEach(function(item) {
+				JSON.parse(item.dataset.tags || '[]').forEach(function(tag) {
+					if (!seen[tag.toLowerCase()]) {
+						seen[tag.toLowerCase()] = true;
+						tags.push(tag);
+					}
+				});
+			});
+			var selected = tagFilter.value;
+			while (tagFilter.options.length > 1) {
+				tagFilter.remove(1);
+			}
+			tags.sort().forEach(function(tag) {
+				var option = document.createElement('option');
+				option.value = tag;
+				option.textContent = tag;
+				tagFilter.appendChild(option);
+			});
+			tagFilter.value = selected;
+		}
+
+		// 在卡片上显示审阅记录：状态按钮、标签和备注，并记录到容器上供筛选使用
+		function renderTriage(container, triage) {
+			triage = triage || {state: 'unreviewed'};
+			container.dataset.triage = triage.state;
+			container.dataset.tags = JSON.stringify(triage.tags || []);
+			triageStates.forEach(function(state) {
+				container.classList.toggle('triage-' + state.value, state.value === triage.state && state.value !== 'unreviewed');
+			});
+			var bar = container.querySelector('.triage-bar');
+			if (bar) {
+				bar.querySelectorAll('button').forEach(function(button) {
+					button.classList.toggle('active', button.dataset.state === triage.state);
+				});
+				var tagsInput = bar.querySelector('input');
+				var noteInput = bar.querySelector('textarea');
+				if (document.activeElement !== tagsInput) tagsInput.value = (triage.tags || []).join(', ');
+				if (document.activeElement !== noteInput) noteInput.value = triage.note || '';
+			}
+			if (container === lightboxItem) {
+				showLightbox(container);
+			}
+		}
+
+		// 保存审阅记录，只提交修改的字段
+		function saveTriage(container, update) {
+			fetch('/api/v1/results/' + encodeURIComponent(container.dataset.resultId) + '/triage', {
+				method: 'PUT',
+				headers: {'Content-Type': 'application/json', 'X-CSRF-Token': csrfToken},
+				body: JSON.stringify(update)
+			}).then(function(response) {
+				return response.json();
+			}).then(function(data) {
+				if (data.error) {
+					throw new Error(data.error.message);
+				}
+				renderTriage(container, data);
+				updateTagFilter(container);
+				if (container.parentNode === screenshotsGrid) {
+					applyFilters(container);
+				}
+			}).catch(function(error) {
+				showMessage('保存审阅记录失败: ' + error.message, true);
+			});
+		}
+
+		// 在卡片下方添加审阅栏，没有结果ID（获取失败）时不能审阅
+		function appendTriage(container, result) {
+			if (!result || !result.id) return;
+			container.dataset.resultId = result.id;
+			var bar = document.createElement('div');
+			bar.className = 'triage-bar';
+			triageStates.forEach(function(state) {
+				var button = document.createElement('button');
+				button.dataset.state = state.value;
+				button.textContent = state.label;
+				button.title = '快捷键 ' + state.key;
+				button.onclick = function() {
+					saveTriage(container, {state: state.value});
+				};
+				bar.appendChild(button);
+			});
+			var tagsInput = document.createElement('input');
+			tagsInput.type = 'text';
+			tagsInput.placeholder = '标签，用逗号分隔';
+			tagsInput.addEventListener('change', function() {
+				saveTriage(container, {tags: tagsInput.value.split(/[,，]/)});
+			});
+			var noteInput = document.createElement('textarea');
+			noteInput.rows = 2;
+			noteInput.placeholder = '备注';
+			noteInput.addEventListener('change', function() {
+				saveTriage(container, {note: noteInput.value});
+			});
+			bar.appendChild(tagsInput);
+			bar.appendChild(noteInput);
+			container.appendChild(bar);
+			renderTriage(container, result.triage);
+			updateTagFilter(container);
+			applyFilters(container);
+		}
+
+		// 当前选中的卡片（j/k 切换）和大图中显示的卡片
+		var selectedItem = null;
+		var lightboxItem = null;
+
+		function selectItem(item) {
+			if (selectedItem) selectedItem.classList.remove('selected');
+			selectedItem = item;
+			if (!item) return;
+			item.classList.add('selected');
+			item.scrollIntoView({block: 'nearest', behavior: 'smooth'});
+			if (lightboxItem) {
+				showLightbox(item);
+			}
+		}
+
+		// 在网格中当前显示（未被筛选隐藏）的卡片间移动选中项
+		function moveSelection(delta) {
+			var items = Array.from(screenshotsGrid.querySelectorAll('.screenshot-item')).filter(function(item) {
+				return item.style.display !== 'none';
+			});
+			if (items.length === 0) return;
+			var index = items.indexOf(selectedItem);
+			if (index < 0) {
+				index = delta > 0 ? 0 : items.length - 1;
+			} else {
+				index = Math.min(items.length - 1, Math.max(0, index + delta));
+			}
+			selectItem(items[index]);
+		}
+
+		// 大图查看：显示原图、标题、URL和审阅状态
+		function showLightbox(item) {
+			lightboxItem = item;
+			lightboxImg.style.display = item.dataset.image ? 'block' : 'none';
+			if (item.dataset.image) lightboxImg.src = item.dataset.image;
+			lightboxCaption.textContent = '';
+			var text = item.dataset.title ? item.dataset.title + ' · ' : '';
+			text += item.querySelector('.url-text').textContent;
+			if (item.dataset.resultId) {
+				text += ' · ' + triageLabel(item.dataset.triage || 'unreviewed');
+			}
+			var tags = JSON.parse(item.dataset.tags || '[]');
+			if (tags.length > 0) text += ' · ' + tags.join(', ');
+			lightboxCaption.appendChild(document.createTextNode(item.dataset.image ? text : '截图失败 · ' + text));
+			if (item.dataset.image) {
+				var link = document.createElement('a');
+				link.href = item.dataset.image;
+				link.target = '_blank';
+				link.textContent = '打开原图';
+				lightboxCaption.appendChild(link);
+			}
+			lightbox.style.display = 'flex';
+		}
+
+		function closeLightbox() {
+			lightboxItem = null;
+			lightbox.style.display = 'none';
+			lightboxImg.removeAttribute('src');
+		}
+
+		lightbox.addEventListener('click', function(e) {
+			if (e.target === lightbox || e.target === lightboxImg) closeLightbox();
+		});
+
+		// 键盘审阅：j/k 切换截图，1-3 标记，0 恢复未审阅，Enter 查看大图，Esc 关闭
+		document.addEventListener('keydown', function(e) {
+			var tag = e.target.tagName;
+			if (tag === 'INPUT' || tag === 'TEXTAREA' || tag === 'SELECT' || e.ctrlKey || e.metaKey || e.altKey) return;
+			// 按钮和链接上的回车保留原有行为
+			if (e.key === 'Enter' && (tag === 'BUTTON' || tag === 'A')) return;
+			if (batchResultsContainer.style.display === 'none' && !lightboxItem) return;
+			switch (e.key) {
+			case 'j':
+				moveSelection(1);
+				break;
+			case 'k':
+				moveSelection(-1);
+				break;
+			case 'Enter':
+			case 'o':
+				if (!selectedItem) return;
+				showLightbox(selectedItem);
+				break;
+			case 'Escape':
+				if (!lightboxItem) return;
+				closeLightbox();
+				break;
+			default:
+				var target = lightboxItem || selectedItem;
+				var state = triageStates.filter(function(s) { return s.key === e.key; })[0];
+				if (!state || !target || !target.dataset.resultId) return;
+				saveTriage(target, {state: state.value});
+			}
+			e.preventDefault();
+		});
+
+		// 查找网格中某个URL的截图，用作分组代表图
+		function findScreenshotSrc(url) {
+			var src = '';
+			screenshotsGrid.querySelectorAll('.screenshot-item').forEach(function(item) {
+				var text = item.querySelector('.url-text');
+				if (text && text.textContent === url) {
+					src = item.querySelector('img').src;
+				}
+			});
+			return src;
+		}
+
+		// 相似分组视图：每组显示一张代表截图、数量和成员URL
+		function showClusters() {
+			if (!clusterToggle.checked) {
+				clustersView.style.display = 'none';
+				screenshotsGrid.style.display = 'grid';
+				return;
+			}
+			fetch('/clusters?distance=' + encodeURIComponent(clusterDistance.value))
+			.then(function(response) {
+				if (!response.ok) throw new Error('获取相似分组失败');
+				return response.json();
+			})
+			.then(function(data) {
+				clustersView.innerHTML = '';
+				(data.clusters || []).forEach(function(cluster) {
+					var card = document.createElement('div');
+					card.className = 'cluster-item';
+					card.style.border = '1px solid #ddd';
+					card.style.borderRadius = '4px';
+					card.style.padding = '10px';
+					card.style.boxShadow = '0 2px 4px rgba(0,0,0,0.1)';
+					card.style.backgroundColor = 'white';
+					card.style.display = 'flex';
+					card.style.flexDirection = 'column';
+
+					var img = document.createElement('img');
+					img.src = findScreenshotSrc(cluster.representative);
+					img.style.maxWidth = '100%';
+					img.style.height = 'auto';
+					img.style.marginBottom = '10px';
+					img.style.borderRadius = '4px';
+
+					var heading = document.createElement('p');
+					heading.style.margin = '0 0 4px 0';
+					heading.style.fontSize = '14px';
+					heading.textContent = cluster.count + ' 个相似页面';
+
+					var members = document.createElement('details');
+					members.style.fontSize = '12px';
+					var summary = document.createElement('summary');
+					summary.textContent = cluster.representative;
+					summary.style.wordBreak = 'break-all';
+					members.appendChild(summary);
+					var list = document.createElement('ul');
+					cluster.members.forEach(function(member) {
+						var li = document.createElement('li');
+						li.textContent = member;
+						list.appendChild(li);
+					});
+					members.appendChild(list);
+
+					card.appendChild(img);
+					card.appendChild(heading);
+					card.appendChild(members);
+					clustersView.appendChild(card);
+				});
+				screenshotsGrid.style.display = 'none';
+				clustersView.style.display = 'grid';
+			})
+			.catch(function(error) {
+				console.error('相似分组失败:', error);
+			});
+		}
+
+		clusterToggle.addEventListener('change', showClusters);
+		clusterDistance.addEventListener('change', showClusters);
+
+		// 在截图下方显示回退说明（元素未找到时回退为裁剪区域，裁剪也失败时回退为整页或视口截图）
+		function appendResultNote(container, result) {
+			if (!result) return;
+			if (result.fallback) {
+				var note = document.createElement('p');
+				note.className = 'result-note';
+				note.textContent = '已回退为' + ({clip: '区域', fullPage: '整页'}[result.mode] || '视口') + '截图: ' + result.fallback;
+				container.appendChild(note);
+			}
+			// 失败原因或HTTP错误状态码，错误类型记录到容器上
+			if (result.errorKind) {
+				var error = document.createElement('p');
+				error.className = 'result-note result-error';
+				error.textContent = result.error ? result.error : 'HTTP ' + result.statusCode;
+				error.title = result.errorKind;
+				container.appendChild(error);
+				container.dataset.errorKind = result.errorKind;
+			}
+		}
+
+		// 创建截图缩略图，点击打开原图；没有截图的失败结果显示为失败状态块（错误类型、URL和时间）
+		function createScreenshotElement(url, result) {
+			if (result && result.thumb) {
+				var img = document.createElement('img');
+				img.src = result.thumb;
+				img.loading = 'lazy';
+				img.style.maxWidth = '100%';
+				img.style.height = 'auto';
+				img.style.marginBottom = '10px';
+				img.style.borderRadius = '4px';
+				img.style.cursor = 'pointer';
+				img.title = '点击查看大图';
+				img.addEventListener('click', function() {
+					selectItem(img.parentNode);
+					showLightbox(img.parentNode);
+				});
+				return img;
+			}
+			var failed = document.createElement('div');
+			failed.className = 'capture-failed';
+			var heading = document.createElement('strong');
+			heading.textContent = result ? '截图失败' : '获取截图失败';
+			failed.appendChild(heading);
+			if (result && result.errorKind) {
+				var kind = document.createElement('span');
+				kind.textContent = result.error ? result.error.split(':')[0] : result.errorKind;
+				failed.appendChild(kind);
+			}
+			var target = document.createElement('span');
+			target.className = 'capture-failed-url';
+			target.textContent = url;
+			failed.appendChild(target);
+			if (result && result.capturedAt) {
+				var time = document.createElement('span');
+				time.textContent = new Date(result.capturedAt).toLocaleString();
+				failed.appendChild(time);
+			}
+			return failed;
+		}
+
+		// 创建网格中的一项：截图、分类、标题、URL及各类附加信息
+		function createResultItem(url, result, runId) {
+			var screenshotContainer = document.createElement('div');
+			screenshotContainer.className = 'screenshot-item';
+			screenshotContainer.style.border = '1px solid #ddd';
+			screenshotContainer.style.borderRadius = '4px';
+			screenshotContainer.style.padding = '10px';
+			screenshotContainer.style.boxShadow = '0 2px 4px rgba(0,0,0,0.1)';
+			screenshotContainer.style.backgroundColor = 'white';
+			screenshotContainer.style.display = 'flex';
+			screenshotContainer.style.flexDirection = 'column';
+			// 截图状态、原图地址和标题，供筛选和大图查看使用
+			screenshotContainer.dataset.status = !result ? 'failed' : !result.errorKind ? 'ok' :
+				result.errorKind === 'http-status' ? 'http-error' : 'failed';
+			if (result && result.image) screenshotContainer.dataset.image = result.image;
+			if (result && result.title) screenshotContainer.dataset.title = result.title;
+			screenshotContainer.addEventListener('click', function() {
+				if (selectedItem !== screenshotContainer) selectItem(screenshotContainer);
+			});
+
+			var urlText = document.createElement('p');
+			urlText.className = 'url-text';
+			urlText.textContent = url;
+			urlText.style.fontSize = '12px';
+			urlText.style.color = '#666';
+			urlText.style.wordBreak = 'break-all';
+			urlText.style.margin = '0';
+			urlText.style.flexGrow = '1';
+
+			screenshotContainer.appendChild(createScreenshotElement(url, result));
+			appendCategory(screenshotContainer, result);
+			appendResultTitle(screenshotContainer, result);
+			screenshotContainer.appendChild(urlText);
+			appendResultNote(screenshotContainer, result);
+			appendArtifactLinks(screenshotContainer, result, runId);
+			appendTechnologies(screenshotContainer, result);
+			appendFavicon(screenshotContainer, result, runId);
+			appendCertificate(screenshotContainer, result);
+			appendPageEvents(screenshotContainer, result);
+			appendTriage(screenshotContainer, result);
+
+			var recaptureBtn = document.createElement('button');
+			recaptureBtn.className = 'recapture-btn';
+			recaptureBtn.textContent = '重新截图';
+			recaptureBtn.onclick = function() {
+				recaptureItem(screenshotContainer, url, recaptureBtn);
+			};
+			screenshotContainer.appendChild(recaptureBtn);
+			return screenshotContainer;
+		}
+
+		// 检查网格中是否已有该URL
+		function hasResultItem(url) {
+			var existingItems = screenshotsGrid.querySelectorAll('.screenshot-item');
+			for (var i = 0; i < existingItems.length; i++) {
+				var p = existingItems[i].querySelector('.url-text');
+				if (p && p.textContent === url) {
+					return true;
 				}
 			}
-			
-			console.log('尝试显示已完成的截图:', normalizedUrl);
-			
-			// 获取指定URL的截图
-			fetch('/get-batch-screenshots', {
+			return false;
+		}
+
+		// 显示单个已完成的截图，只获取该URL的结果元数据，图片按需从缩略图地址加载
+		function showCompletedScreenshot(url) {
+			if (hasResultItem(url)) {
+				return;
+			}
+			fetch('/api/results?url=' + encodeURIComponent(url), {
 				method: 'GET',
 				headers: {'Content-Type': 'application/json'}
 			}).then(function(response) {
 				return response.json();
 			}).then(function(data) {
-				console.log('获取到的截图数据:', data);
-				if (data.screenshots) {
-					// 确保截图网格已显示
-					batchResultsContainer.style.display = 'block';
-					
-					// 检查是否已经存在该URL的截图
-					var existingItems = screenshotsGrid.querySelectorAll('.screenshot-item');
-					var alreadyExists = false;
-					for (var i = 0; i < existingItems.length; i++) {
-						var p = existingItems[i].querySelector('p');
-						if (p && p.textContent === normalizedUrl) {
-							alreadyExists = true;
-							break;
-						}
-					}
-
-					if (!alreadyExists) {
-						// 创建新的截图容器
-						var screenshotContainer = document.createElement('div');
-						screenshotContainer.className = 'screenshot-item';
-						screenshotContainer.style.border = '1px solid #ddd';
-						screenshotContainer.style.borderRadius = '4px';
-						screenshotContainer.style.padding = '10px';
-						screenshotContainer.style.boxShadow = '0 2px 4px rgba(0,0,0,0.1)';
-						screenshotContainer.style.backgroundColor = 'white';
-						screenshotContainer.style.display = 'flex';
-						screenshotContainer.style.flexDirection = 'column';
-					 
-						// 添加淡入动画
-						screenshotContainer.style.opacity = '0';
-						screenshotContainer.style.transform = 'translateY(20px)';
-						screenshotContainer.style.transition = 'opacity 0.3s ease, transform 0.3s ease';
-					 
-						var screenshotImg = document.createElement('img');
-						if (data.screenshots[normalizedUrl]) {
-							screenshotImg.src = 'data:image/png;base64,' + data.screenshots[normalizedUrl];
-						} else {
-							// 如果没有找到该URL的截图，显示错误信息
-							console.warn('未找到URL的截图:', normalizedUrl);
-							// 可以选择使用一个默认的占位图
-							screenshotImg.src = 'data:image/svg+xml;charset=utf-8,%3Csvg xmlns="http://www.w3.org/2000/svg" width="1200" height="800" viewBox="0 0 1200 800"%3E%3Crect width="1200" height="800" fill="%23f5f5f5"/%3E%3Ctext x="600" y="400" font-family="Arial" font-size="24" text-anchor="middle" fill="%23666"%3E截图失败%3E%3C/text%3E%3Ctext x="600" y="440" font-family="Arial" font-size="16" text-anchor="middle" fill="%23999"%3E' + encodeURIComponent(normalizedUrl) + '%3E%3C/text%3E%3C/svg%3E';
-						}
-						screenshotImg.style.maxWidth = '100%';
-						screenshotImg.style.height = 'auto';
-						screenshotImg.style.marginBottom = '10px';
-						screenshotImg.style.borderRadius = '4px';
-					 
-						var urlText = document.createElement('p');
-						urlText.textContent = normalizedUrl;
-						urlText.style.fontSize = '12px';
-						urlText.style.color = '#666';
-						urlText.style.wordBreak = 'break-all';
-						urlText.style.margin = '0';
-						urlText.style.flexGrow = '1';
-					 
-						screenshotContainer.appendChild(screenshotImg);
-						screenshotContainer.appendChild(urlText);
-						screenshotsGrid.appendChild(screenshotContainer);
-					 
-						// 触发动画
-						setTimeout(function() {
-							screenshotContainer.style.opacity = '1';
-							screenshotContainer.style.transform = 'translateY(0)';
-						}, 10);
-					}
-				} else {
-					console.error('获取的截图数据为空');
-				}
+				var result = data.results && data.results[0];
+				// 后端返回标准化后的URL
+				var normalizedUrl = result ? result.url : url;
+				batchResultsContainer.style.display = 'block';
+				if (hasResultItem(normalizedUrl)) return;
+
+				var screenshotContainer = createResultItem(normalizedUrl, result, data.runId);
+				// 添加淡入动画
+				screenshotContainer.style.opacity = '0';
+				screenshotContainer.style.transform = 'translateY(20px)';
+				screenshotContainer.style.transition = 'opacity 0.3s ease, transform 0.3s ease';
+				screenshotsGrid.appendChild(screenshotContainer);
+
+				// 触发动画
+				setTimeout(function() {
+					screenshotContainer.style.opacity = '1';
+					screenshotContainer.style.transform = 'translateY(0)';
+				}, 10);
 			}).catch(function(error) {
 				console.error('获取截图失败:', error);
-				// 即使获取失败，也尝试创建一个错误占位图
+				// 即使获取失败，也显示失败状态
 				batchResultsContainer.style.display = 'block';
-				var existingItems = screenshotsGrid.querySelectorAll('.screenshot-item');
-				var alreadyExists = false;
-				for (var i = 0; i < existingItems.length; i++) {
-					var p = existingItems[i].querySelector('p');
-					if (p && p.textContent === normalizedUrl) {
-						alreadyExists = true;
-						break;
-					}
-				}
-				if (!alreadyExists) {
-					var screenshotContainer = document.createElement('div');
-					screenshotContainer.className = 'screenshot-item';
-					screenshotContainer.style.border = '1px solid #ddd';
-					screenshotContainer.style.borderRadius = '4px';
-					screenshotContainer.style.padding = '10px';
-					screenshotContainer.style.boxShadow = '0 2px 4px rgba(0,0,0,0.1)';
-					screenshotContainer.style.backgroundColor = 'white';
-					var screenshotImg = document.createElement('img');
-					screenshotImg.src = 'data:image/svg+xml;charset=utf-8,%3Csvg xmlns="http://www.w3.org/2000/svg" width="1200" height="800" viewBox="0 0 1200 800"%3E%3Crect width="1200" height="800" fill="%23f5f5f5"/%3E%3Ctext x="600" y="400" font-family="Arial" font-size="24" text-anchor="middle" fill="%23666"%3E获取截图失败%3E%3Ctext x="600" y="440" font-family="Arial" font-size="16" text-anchor="middle" fill="%23999"%3E' + encodeURIComponent(normalizedUrl) + '%3E%3C/text%3E%3C/svg%3E';
-					screenshotImg.style.maxWidth = '100%';
-					var urlText = document.createElement('p');
-					urlText.textContent = normalizedUrl;
-					urlText.style.fontSize = '12px';
-					urlText.style.color = '#666';
-					screenshotContainer.appendChild(screenshotImg);
-					screenshotContainer.appendChild(urlText);
-					screenshotsGrid.appendChild(screenshotContainer);
+				if (!hasResultItem(url)) {
+					screenshotsGrid.appendChild(createResultItem(url, null, null));
 				}
 			});
 		}
 
-		// 显示批量截图结果
-		function showBatchScreenshots() {
-			fetch('/get-batch-screenshots', {
+		// 分页获取当前任务的全部结果元数据
+		function fetchAllResults(offset, collected) {
+			return fetch('/api/results?offset=' + offset + '&limit=200', {
 				method: 'GET',
 				headers: {'Content-Type': 'application/json'}
 			}).then(function(response) {
 				return response.json();
 			}).then(function(data) {
-				if (data.screenshots && Object.keys(data.screenshots).length > 0) {
+				var results = collected.concat(data.results || []);
+				if (results.length < data.total && (data.results || []).length > 0) {
+					return fetchAllResults(results.length, results);
+				}
+				return {runId: data.runId, results: results};
+			});
+		}
+
+		// 显示批量截图结果（成功的截图和失败的结果都显示在网格中）
+		function showBatchScreenshots() {
+			fetchAllResults(0, []).then(function(data) {
+				if (data.results.length > 0) {
 					// 清空截图网格
 					screenshotsGrid.innerHTML = '';
-					
-					// 添加每个截图到网格
-					for (var url in data.screenshots) {
-						if (data.screenshots.hasOwnProperty(url)) {
-							var screenshotContainer = document.createElement('div');
-							screenshotContainer.className = 'screenshot-item';
-							screenshotContainer.style.border = '1px solid #ddd';
-							screenshotContainer.style.borderRadius = '4px';
-							screenshotContainer.style.padding = '10px';
-							screenshotContainer.style.boxShadow = '0 2px 4px rgba(0,0,0,0.1)';
-							screenshotContainer.style.backgroundColor = 'white';
-							screenshotContainer.style.display = 'flex';
-							screenshotContainer.style.flexDirection = 'column';
-							
-							var screenshotImg = document.createElement('img');
-							screenshotImg.src = 'data:image/png;base64,' + data.screenshots[url];
-							screenshotImg.style.maxWidth = '100%';
-							screenshotImg.style.height = 'auto';
-							screenshotImg.style.marginBottom = '10px';
-							screenshotImg.style.borderRadius = '4px';
-							
-							var urlText = document.createElement('p');
-							urlText.textContent = url;
-							urlText.style.fontSize = '12px';
-							urlText.style.color = '#666';
-							urlText.style.wordBreak = 'break-all';
-							urlText.style.margin = '0';
-							urlText.style.flexGrow = '1';
-							
-							screenshotContainer.appendChild(screenshotImg);
-							screenshotContainer.appendChild(urlText);
-							screenshotsGrid.appendChild(screenshotContainer);
-						}
-					}
-					
+					selectItem(null);
+
+					// 添加每个结果到网格
+					data.results.forEach(function(result) {
+						screenshotsGrid.appendChild(createResultItem(result.url, result, data.runId));
+					});
+
 					// 显示批量截图结果区域
 					batchResultsContainer.style.display = 'block';
 				} else {
@@ -417,6 +1741,170 @@ func startServer() string {
 			return normalizedUrl;
 		}
 
+		// 当前订阅的任务：WebSocket控制通道、任务ID、已收到的最后一个事件ID
+		var jobSocket = null;
+		var currentJobId = null;
+		var lastJobEventId = 0;
+		var commandSeq = 0;
+
+		// 更新进度条和进度文本
+		function handleJobEvent(data) {
+			if (data.status) {
+				progressText.textContent = data.status;
+			}
+			if (data.progress !== undefined) {
+				progressBar.style.width = data.progress + '%';
+			}
+		}
+
+		// 同步暂停状态和并发数到控制区
+		function updateJobControls(job) {
+			if (job.paused !== undefined) {
+				pauseJobBtn.textContent = job.paused ? '继续' : '暂停';
+				pauseJobBtn.dataset.paused = job.paused ? '1' : '';
+			}
+			if (job.concurrency) {
+				concurrencyInput.value = job.concurrency;
+			}
+		}
+
+		// 按事件类型处理任务事件
+		function dispatchJobEvent(event) {
+			var data = event.data || {};
+			lastJobEventId = event.id;
+			handleJobEvent(data);
+			switch (event.type) {
+			case 'result':
+				// 有已完成的URL时立即显示截图，showCompletedScreenshot函数会进行标准化
+				if (data.completedUrl) {
+					showCompletedScreenshot(data.completedUrl);
+				}
+				break;
+			case 'error':
+				if (data.error) {
+					showMessage(data.error, true);
+				}
+				if (data.completedUrl) {
+					showCompletedScreenshot(data.completedUrl);
+				}
+				break;
+			case 'control':
+				updateJobControls(data);
+				break;
+			case 'completed':
+				currentJobId = null;
+				jobControls.style.display = 'none';
+				updateUrlList(currentUrls);
+				progressBar.style.width = '100%';
+				showMessage(data.canceled ? '批量截图已取消' : '批量截图完成', !!data.canceled);
+				if (data.report) {
+					reportLink.href = data.report;
+					reportLink.style.display = 'inline';
+				}
+				// 获取完整结果，确保实时更新过程中遗漏的URL也能显示
+				showBatchScreenshots();
+				loadRuns();
+				// 不立即隐藏进度条，让用户看到最终完成状态
+				setTimeout(function() {
+					progressContainer.style.display = 'none';
+				}, 1000);
+				break;
+			}
+		}
+
+		// 通过控制通道发送命令，服务器回复执行结果
+		function sendJobCommand(command) {
+			if (!jobSocket || jobSocket.readyState !== WebSocket.OPEN) {
+				showMessage('任务控制通道未连接', true);
+				return;
+			}
+			command.id = String(++commandSeq);
+			jobSocket.send(JSON.stringify(command));
+		}
+
+		// 订阅任务：通过WebSocket接收事件并发送控制命令，断线后按最后的事件ID重连补发
+		function subscribeJob(jobId) {
+			if (jobSocket) {
+				jobSocket.onclose = null;
+				jobSocket.close();
+			}
+			if (currentJobId !== jobId) {
+				lastJobEventId = 0;
+			}
+			currentJobId = jobId;
+			progressContainer.style.display = 'block';
+			jobControls.style.display = 'block';
+			updateUrlList(currentUrls);
+
+			var scheme = location.protocol === 'https:' ? 'wss://' : 'ws://';
+			var socket = new WebSocket(scheme + location.host + '/api/v1/jobs/' + encodeURIComponent(jobId) +
+				'/ws?lastEventId=' + lastJobEventId, 'webcut');
+			jobSocket = socket;
+			socket.onmessage = function(e) {
+				var message = JSON.parse(e.data);
+				if (message.type === 'event') {
+					dispatchJobEvent(message.event);
+				} else if (message.type === 'reply') {
+					if (message.error) {
+						showMessage(message.error, true);
+					} else if (message.job) {
+						updateJobControls(message.job);
+					}
+				}
+			};
+			socket.onclose = function() {
+				if (jobSocket !== socket) {
+					return;
+				}
+				jobSocket = null;
+				// 任务未结束时连接中断，稍后重连
+				if (currentJobId === jobId) {
+					setTimeout(function() {
+						if (currentJobId === jobId && !jobSocket) {
+							subscribeJob(jobId);
+						}
+					}, 1000);
+				}
+			};
+		}
+
+		pauseJobBtn.addEventListener('click', function() {
+			sendJobCommand({action: pauseJobBtn.dataset.paused ? 'resume' : 'pause'});
+		});
+		cancelJobBtn.addEventListener('click', function() {
+			if (confirm('确定取消整个任务吗？')) {
+				sendJobCommand({action: 'cancel-job'});
+			}
+		});
+		concurrencyBtn.addEventListener('click', function() {
+			sendJobCommand({action: 'concurrency', concurrency: parseInt(concurrencyInput.value, 10) || 0});
+		});
+
+		// 页面刷新后重新接入正在运行的任务
+		fetch('/api/v1/jobs').then(function(response) {
+			return response.json();
+		}).then(function(data) {
+			var running = (data.jobs || []).filter(function(job) {
+				return job.status === 'running';
+			})[0];
+			if (!running) {
+				return;
+			}
+			// 先显示已完成的结果，补发的事件中已显示的URL不再重复获取
+			fetchAllResults(0, []).then(function(results) {
+				results.results.forEach(function(result) {
+					screenshotsGrid.appendChild(createResultItem(result.url, result, results.runId));
+				});
+				if (results.results.length > 0) {
+					batchResultsContainer.style.display = 'block';
+				}
+				updateJobControls(running);
+				subscribeJob(running.id);
+			});
+		}).catch(function(error) {
+			console.error('获取任务列表失败:', error);
+		});
+
 		// 批量截图按钮点击事件
 		batchCaptureBtn.addEventListener('click', function() {
 			fetch('/get-urls', {
@@ -435,70 +1923,22 @@ func startServer() string {
 				progressBar.style.width = '0%';
 				progressText.textContent = '准备开始批量截图...';
 
-				// 发送批量截图请求
-				fetch('/batch-capture', {
+				// 提交批量截图任务，再订阅任务事件获取进度
+				fetch('/api/v1/jobs', {
 					method: 'POST',
-					headers: {'Content-Type': 'application/json'},
-					body: JSON.stringify({fullPage: false})
+					headers: {'Content-Type': 'application/json', 'X-CSRF-Token': csrfToken},
+					body: JSON.stringify({options: getCaptureOptions()})
 				}).then(function(response) {
-					// 处理服务器发送的事件流
-					const reader = response.body.getReader();
-					const decoder = new TextDecoder();
-
-					function readStream() {
-						reader.read().then(({done, value}) => {
-							if (done) {
-								// 批量截图完成后，获取并显示完整结果
-								// 确保所有URL都能正确显示，即使在实时更新过程中有通知丢失
-								showBatchScreenshots();
-								return;
-							}
-
-							const chunk = decoder.decode(value, {stream: true});
-							// 处理每一行数据
-							const lines = chunk.split('\n');
-							lines.forEach(function(line) {
-								if (line.trim().startsWith('data:')) {
-									try {
-										const data = JSON.parse(line.trim().substring(5));
-										if (data.status) {
-											progressText.textContent = data.status;
-										}
-										if (data.progress !== undefined) {
-											progressBar.style.width = data.progress + '%';
-										}
-										if (data.error) {
-											showMessage(data.error, true);
-										}
-										 
-										// 检查是否有已完成的URL并立即显示截图
-										if (data.completedUrl) {
-											// 这里我们直接使用原始URL，showCompletedScreenshot函数会进行标准化
-											showCompletedScreenshot(data.completedUrl);
-										}
-										// 检查是否所有截图都已完成
-										if (data.allCompleted) {
-											// 已经从服务器获取了正确的进度文本，不需要硬编码
-											progressBar.style.width = '100%';
-											showMessage('批量截图完成', false);
-											// 再次调用showBatchScreenshots确保所有URL都能显示
-											showBatchScreenshots();
-											// 不立即隐藏进度条，让用户看到最终完成状态
-											setTimeout(function() {
-												progressContainer.style.display = 'none';
-											}, 1000);
-										}
-									} catch (e) {
-										console.error('解析进度数据失败:', e);
-									}
-								}
-							});
-
-							readStream();
-						});
+					return response.json();
+				}).then(function(data) {
+					if (data.error) {
+						showMessage(data.error.message, true);
+						progressContainer.style.display = 'none';
+						return;
 					}
-
-					readStream();
+					reportLink.style.display = 'none';
+					updateJobControls(data);
+					subscribeJob(data.id);
 				}).catch(function(error) {
 					showMessage('批量截图失败: ' + error.message, true);
 					progressContainer.style.display = 'none';
@@ -524,7 +1964,7 @@ func startServer() string {
 				// 发送URL列表到服务器
 				fetch('/load-urls', {
 					method: 'POST',
-					headers: {'Content-Type': 'application/json'},
+					headers: {'Content-Type': 'application/json', 'X-CSRF-Token': csrfToken},
 					body: JSON.stringify({urls: urls})
 				}).then(function(response) {
 					return response.json();
@@ -534,6 +1974,10 @@ func startServer() string {
 						updateUrlList(urls);
 						// 清空之前的截图结果
 						screenshotsGrid.innerHTML = '';
+						selectItem(null);
+						updateTagFilter();
+						clusterToggle.checked = false;
+						showClusters();
 						batchResultsContainer.style.display = 'none';
 					} else {
 						showMessage(data.error || 'URL列表加载失败', true);
@@ -552,46 +1996,194 @@ func startServer() string {
 	<div class="container">
 		<h1>WebCut - 网页快照工具</h1>
 		<div>
-			<!-- 隐藏URL输入框，因为不再支持单独截图 -->
-			<input type="text" id="urlInput" placeholder="请输入要截图的网址" style="display: none;">
+			<input type="text" id="urlInput" placeholder="请输入要截图的网址，按回车截图">
 		</div>
-		<!-- 隐藏单个截图按钮 -->
-		<button id="captureBtn" style="display: none;">截取屏幕</button>
+		<button id="captureBtn">截取屏幕</button>
 		<button id="loadListBtn">加载URL列表</button>
 		<button id="batchCaptureBtn">批量截图</button>
 		<input type="file" id="fileInput" accept=".txt">
+		<div class="capture-options">
+			<label><input type="checkbox" id="fullPageInput"> 整页截图</label>
+			<label>元素选择器
+				<select id="selectorTypeInput">
+					<option value="css">CSS</option>
+					<option value="xpath">XPath</option>
+				</select>
+				<input type="text" id="selectorInput" placeholder="如 #login-form，留空截取视口">
+			</label>
+			<label>裁剪区域
+				<input type="number" id="clipX" placeholder="x" style="width: 70px;">
+				<input type="number" id="clipY" placeholder="y" style="width: 70px;">
+				<input type="number" id="clipWidth" placeholder="宽" style="width: 70px;">
+				<input type="number" id="clipHeight" placeholder="高" style="width: 70px;">
+			</label>
+			<label><input type="checkbox" id="savePdfInput"> 保存PDF</label>
+			<label><input type="checkbox" id="saveMhtmlInput"> 保存MHTML</label>
+			<label><input type="checkbox" id="saveHarInput"> 记录HAR</label>
+			<label><input type="checkbox" id="harBodiesInput"> HAR包含响应体（单个不超过1MB）</label>
+			<label><input type="checkbox" id="retryBlankInput" checked> 空白页延长等待重试</label>
+			<br>
+			<label>失败重试
+				<input type="number" id="retriesInput" value="1" min="0" max="10" style="width: 50px;">次
+			</label>
+			<label>首次重试等待
+				<input type="number" id="retryDelayInput" value="1" min="0" step="0.5" style="width: 60px;">秒（指数退避）
+			</label>
+			<label><input type="checkbox" id="finalPassInput"> 任务结束后低并发重试失败目标</label>
+			<br>
+			<label>视口
+				<input type="number" id="viewportWidthInput" placeholder="1920" min="1" max="8192" style="width: 70px;">×
+				<input type="number" id="viewportHeightInput" placeholder="1080" min="1" max="8192" style="width: 70px;">
+			</label>
+			<label>等待元素
+				<input type="text" id="waitSelectorInput" placeholder="CSS选择器，页面稳定后等待其可见" style="width: 220px;">
+			</label>
+			<label>额外等待
+				<input type="number" id="waitDelayInput" value="0" min="0" max="30" step="0.5" style="width: 60px;">秒
+			</label>
+			<br>
+			<label>认证用户名
+				<input type="text" id="authUserInput" placeholder="HTTP Basic认证" style="width: 120px;">
+			</label>
+			<label>密码
+				<input type="password" id="authPasswordInput" style="width: 120px;">
+			</label>
+			<label>Cookie
+				<input type="text" id="authCookieInput" placeholder="name=value; name2=value2" style="width: 240px;">
+			</label>
+			<br>
+			<label><input type="checkbox" id="discoverSansInput"> 从证书SAN发现新目标</label>
+			<label>范围
+				<input type="text" id="scopeInput" placeholder="域名/IP/CIDR，逗号分隔，留空为目标的上级域名" style="width: 360px;">
+			</label>
+		</div>
 		<div class="message" id="message"></div>
 		<div class="progress" style="margin-top: 10px; display: none;">
 			<p id="progressText">准备开始批量截图...</p>
 			<div style="width: 100%; height: 20px; background-color: #f0f0f0; border-radius: 10px; overflow: hidden;">
 				<div id="progressBar" style="height: 100%; width: 0%; background-color: #3498db;"></div>
 			</div>
+			<div id="jobControls" class="job-controls" style="display: none;">
+				<button id="pauseJobBtn">暂停</button>
+				<button id="cancelJobBtn">取消任务</button>
+				<label>并发数
+					<input type="number" id="concurrencyInput" min="1" max="10" style="width: 50px;">
+				</label>
+				<button id="concurrencyBtn">调整</button>
+			</div>
 		</div>
 		
 		<div class="img-container" id="imgContainer" style="display: none;">
 			<h3>截图结果</h3>
-			<img id="screenshotImg" alt="网页截图">
+			<p id="singleStatus"></p>
+			<div id="singleResult"></div>
 		</div>
 		
 		<div class="url-list-container">
 			<h3>URL列表</h3>
 			<div id="urlList" class="url-list"></div>
 		</div>
+
+		<div class="search-box">
+			<h3>全文检索</h3>
+			<input type="text" id="searchInput" placeholder="检索所有运行的网址、标题、响应头、产品和页面文本，如 &quot;powered by&quot; server:apache-coyote">
+			<button id="searchBtn">检索</button>
+			<label style="font-size: 14px;"><input type="checkbox" id="searchOnlyHits"> 网格中只显示匹配</label>
+			<p class="triage-hint">"..." 匹配短语，-排除，url: title: header: tech: text: 限定字段，响应头名称加冒号检索该响应头（如 server:nginx）</p>
+			<div id="searchResults"></div>
+		</div>
+
+		<div class="compare-box">
+			<h3>运行对比</h3>
+			<label>之前<select id="baseRunSelect"></select></label>
+			<label>之后<select id="targetRunSelect"></select></label>
+			<label>变化阈值(%)<input type="number" id="compareThreshold" value="1" min="0" max="100" step="0.1"></label>
+			<label>显示
+				<select id="compareFilter">
+					<option value="">有变化</option>
+					<option value="all">全部</option>
+					<option value="new">新增</option>
+					<option value="removed">移除</option>
+					<option value="changed">变化</option>
+					<option value="unchanged">未变</option>
+				</select>
+			</label>
+			<button id="compareBtn" disabled>对比</button>
+			<p class="triage-hint">按规范化URL匹配两次运行的目标，比较截图像素和感知哈希，以及标题、状态、产品和证书</p>
+			<div id="compareResults"></div>
+		</div>
 		
 		<!-- 批量截图结果显示区域 -->
 		<div id="batchResults" style="margin-top: 20px; display: none;">
-			<h3>批量截图结果</h3>
+			<h3>批量截图结果 <a id="reportLink" href="#" target="_blank" style="display: none; font-size: 14px;">查看报告</a></h3>
+			<div style="margin-bottom: 10px; font-size: 14px;">
+				<label>按分类筛选
+					<select id="categoryFilter">
+						<option value="">全部</option>
+					</select>
+				</label>
+				<label style="margin-left: 12px;">按产品筛选
+					<select id="techFilter">
+						<option value="">全部</option>
+					</select>
+				</label>
+				<label style="margin-left: 12px;">按favicon分组
+					<select id="faviconFilter">
+						<option value="">全部</option>
+					</select>
+				</label>
+				<label style="margin-left: 12px;">审阅状态
+					<select id="triageFilter">
+						<option value="">全部</option>
+						<option value="unreviewed">未审阅</option>
+						<option value="interesting">有价值</option>
+						<option value="not-interesting">无价值</option>
+						<option value="follow-up">待跟进</option>
+					</select>
+				</label>
+				<label style="margin-left: 12px;">标签
+					<select id="tagFilter">
+						<option value="">全部</option>
+					</select>
+				</label>
+				<label style="margin-left: 12px;">截图状态
+					<select id="statusFilter">
+						<option value="">全部</option>
+						<option value="ok">成功</option>
+						<option value="http-error">HTTP错误</option>
+						<option value="failed">截图失败</option>
+					</select>
+				</label>
+				<label style="margin-left: 12px;"><input type="checkbox" id="clusterToggle"> 相似分组</label>
+				<label style="margin-left: 8px;">阈值 <input type="number" id="clusterDistance" value="10" min="0" max="64" style="width: 50px; padding: 2px;"></label>
+				<a id="exportJsonLink" href="/export-results?format=json" style="margin-left: 12px;">导出JSON</a>
+				<a id="exportCsvLink" href="/export-results?format=csv" style="margin-left: 8px;">导出CSV</a>
+			</div>
+			<p class="triage-hint">键盘审阅：j/k 切换截图，1 有价值，2 无价值，3 待跟进，0 未审阅，Enter 查看大图，Esc 关闭</p>
+			<div id="clustersView" style="display: none; grid-template-columns: repeat(auto-fill, minmax(300px, 1fr)); gap: 15px;"></div>
 			<div id="screenshotsGrid" style="display: grid; grid-template-columns: repeat(auto-fill, minmax(300px, 1fr)); gap: 15px;">
 				<!-- 截图结果会动态添加到这里 -->
 			</div>
 		</div>
 	</div>
+	<div id="lightbox" class="lightbox">
+		<img id="lightboxImg" alt="截图">
+		<p id="lightboxCaption" class="lightbox-caption"></p>
+	</div>
 </body>
 </html>
 `
 
+	// 以下旧接口已废弃，仅作为界面使用的 /api/v1 兼容别名保留，错误以纯文本返回；新的集成请使用 /api/v1：
+	//   /load-urls、/get-urls → PUT、GET /api/v1/targets
+	//   /capture → POST /api/v1/captures
+	//   /batch-capture → POST /api/v1/jobs 及 GET /api/v1/jobs/{id}/events
+	//   /get-batch-screenshots、/api/results → GET /api/v1/jobs/{id}/results
+	//   /export-results → GET /api/v1/jobs/{id}/export
+	//   /api/images/、/thumb/、/clusters 只供界面显示截图、缩略图和相似分组
+
 	// 加载URL列表
-	http.HandleFunc("/load-urls", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/load-urls", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -624,7 +2216,7 @@ func startServer() string {
 	})
 
 	// 获取URL列表
-	http.HandleFunc("/get-urls", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/get-urls", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -638,13 +2230,13 @@ func startServer() string {
 	})
 
 	// 处理根路径请求
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		tmpl := template.Must(template.New("page").Parse(htmlTemplate))
-		tmpl.Execute(w, PageData{ServerAddr: addr})
+		tmpl.Execute(w, PageData{ServerAddr: addr, CSRFToken: csrfToken(r)})
 	})
 
-	// 处理截图请求（虽然隐藏了按钮，但保留API以便未来可能需要）
-	http.HandleFunc("/capture", func(w http.ResponseWriter, r *http.Request) {
+	// 处理单个截图请求，结果与批量截图一样保存到运行目录
+	mux.HandleFunc("/capture", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -659,8 +2251,8 @@ func startServer() string {
 
 		// 解析JSON请求
 		var req struct {
-			URL      string `json:"url"`
-			FullPage bool   `json:"fullPage"`
+			URL string `json:"url"`
+			CaptureOptions
 		}
 
 		if err := json.Unmarshal(body, &req); err != nil {
@@ -669,51 +2261,31 @@ func startServer() string {
 			return
 		}
 
-		fmt.Printf("准备截图URL: %s\n", req.URL)
-
 		// 捕获截图
-		imgData, err := captureScreenshot(req.URL, req.FullPage)
+		capture, err := captureSingle(req.URL, req.CaptureOptions)
 		if err != nil {
-			fmt.Printf("截图失败: %v\n", err)
-			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("截图失败: %v", err)})
+			response := map[string]interface{}{"error": fmt.Sprintf("截图失败: %v", err), "errorKind": string(errorKindOf(err))}
+			if capture != nil {
+				response["result"] = capture.Result
+				response["runId"] = capture.RunID
+			}
+			json.NewEncoder(w).Encode(response)
 			return
 		}
 
-		// 保存当前截图
-		currentScreenshot = imgData
-
 		// 将截图转换为base64并返回
-		base64Image := base64.StdEncoding.EncodeToString(imgData)
+		base64Image := base64.StdEncoding.EncodeToString(capture.Result.Image)
 		fmt.Println("截图成功，已返回响应")
-		json.NewEncoder(w).Encode(map[string]string{"base64Image": base64Image})
+		json.NewEncoder(w).Encode(map[string]interface{}{"base64Image": base64Image, "result": capture.Result, "runId": capture.RunID})
 	})
 
-	// 批量截图API
-	var batchRunning = false
-	var batchMutex sync.Mutex
-
-	// 处理批量截图请求
-	http.HandleFunc("/batch-capture", func(w http.ResponseWriter, r *http.Request) {
+	// 处理批量截图请求，通过SSE发送进度
+	mux.HandleFunc("/batch-capture", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// 检查是否有批量任务正在运行
-		batchMutex.Lock()
-		if batchRunning {
-			batchMutex.Unlock()
-			http.Error(w, "批量截图任务正在运行，请稍后再试", http.StatusConflict)
-			return
-		}
-		batchRunning = true
-		batchMutex.Unlock()
-		defer func() {
-			batchMutex.Lock()
-			batchRunning = false
-			batchMutex.Unlock()
-		}()
-
 		// 先读取请求体，避免在设置SSE响应头后读取导致连接问题
 		// 读取请求体
 		body, err := ioutil.ReadAll(r.Body)
@@ -724,10 +2296,8 @@ func startServer() string {
 			return
 		}
 
-		// 解析JSON请求
-		var req struct {
-			FullPage bool `json:"fullPage"`
-		}
+		// 解析JSON请求，截图选项（整页、元素选择器、裁剪区域、按URL规则）对整个任务生效
+		var req CaptureOptions
 
 		if err := json.Unmarshal(body, &req); err != nil {
 			fmt.Printf("批量截图JSON解析错误: %v\n", err)
@@ -736,179 +2306,238 @@ func startServer() string {
 			return
 		}
 
-		// 现在已经成功读取并解析了请求体，才设置SSE响应头
-		// 设置响应头为SSE格式
-		w.Header().Set("Content-Type", "text/event-stream")
-		w.Header().Set("Cache-Control", "no-cache")
-		w.Header().Set("Connection", "keep-alive")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-
-		// 确保客户端收到响应头
-		if flusher, ok := w.(http.Flusher); ok {
-			flusher.Flush()
-		}
-
-		// 获取URL列表
+		// 获取URL列表并创建任务，已有任务在运行时直接返回冲突
 		urlListMutex.Lock()
 		urls := make([]string, len(urlList))
 		copy(urls, urlList)
 		urlListMutex.Unlock()
 
-		if len(urls) == 0 {
-			fmt.Fprintf(w, "data: {\"error\": \"URL列表为空，请先加载URL列表\"}\n\n")
+		job, err := startJob(urls, req, requestUser(r))
+		if err == errBatchRunning {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		if err != nil {
+			// 保持旧客户端的行为：错误作为SSE事件返回
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			errData, _ := json.Marshal(map[string]string{"error": err.Error()})
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", EventError, errData)
 			return
 		}
 
-		fmt.Printf("开始批量截图，共 %d 个URL\n", len(urls))
+		// 任务在后台执行，本连接只是任务事件的一个订阅者，断开后可通过 /api/v1/jobs/{id}/events 重新订阅
+		go job.execute()
+		streamJobEvents(w, r, job)
+	})
 
-		// 在开始新的批量截图任务前，重置浏览器池，解决URL列表切换后截图失败的问题
-		// 这会创建全新的浏览器实例，避免使用可能已损坏的上下文
-		resetBrowserPool()
+	// 获取批量截图结果（所有截图base64编码在一个响应中，仅为兼容旧客户端保留，界面使用 /api/results 和 /thumb/）
+	mux.HandleFunc("/get-batch-screenshots", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-		// 清空之前的批量截图结果
+		// 获取批量截图结果
 		batchMutex.Lock()
-		batchScreenshots = make(map[string][]byte)
+		// 将二进制数据转换为base64
+		base64Screenshots := make(map[string]string)
+		for url, imgData := range batchScreenshots {
+			base64Screenshots[url] = base64.StdEncoding.EncodeToString(imgData)
+		}
+		results := make(map[string]*CaptureResult, len(batchResults))
+		for url, result := range batchResults {
+			results[url] = result
+		}
+		runID := batchRunID
 		batchMutex.Unlock()
 
-		// 创建完成的URL通道，用于实时获取已完成的截图
-		completedURLs := make(chan string, len(urls))
-		var wg sync.WaitGroup
-		var totalCount = len(urls)
-
-		// 适当降低并发数量，避免资源耗尽导致超时
-		// 浏览器池大小为10，但实际运行时应保留一些缓冲
-		concurrencyLimit := 5
-		semaphore := make(chan struct{}, concurrencyLimit)
-
-		// 启动并发截图
-		for _, url := range urls {
-			wg.Add(1)
-			semaphore <- struct{}{} // 获取令牌
-			go func(url string) {
-				defer wg.Done()
-				defer func() {
-					<-semaphore // 释放令牌
-					// 确保即使发生panic也能处理
-					if r := recover(); r != nil {
-						fmt.Printf("处理URL %s 时发生panic: %v\n", url, r)
-					}
-				}() // 释放令牌
-
-				fmt.Printf("正在截图URL: %s\n", url)
-
-				// 捕获截图
-				imgData, err := captureScreenshot(url, req.FullPage)
-				if err != nil {
-					fmt.Printf("URL %s 截图失败: %v\n", url, err)
-					// 使用占位图代替失败的截图
-					placeholder := createErrorPlaceholder(1200, 800, url)
-					batchMutex.Lock()
-					batchScreenshots[normalizeURL(url)] = placeholder
-					batchMutex.Unlock()
-					fmt.Printf("URL %s 使用占位图\n", url)
-				} else {
-					// 标准化URL格式并保存截图结果
-					batchMutex.Lock()
-					batchScreenshots[normalizeURL(url)] = imgData
-					batchMutex.Unlock()
+		// 返回base64编码的截图结果及每个URL的截图信息（模式、回退原因、附件等）
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"screenshots": base64Screenshots,
+			"results":     results,
+			"runId":       runID,
+		})
 
-					fmt.Printf("URL %s 截图成功\n", url)
-				}
+		fmt.Println("返回批量截图结果")
+	})
 
-				// 发送原始URL到通道，用于准确计数
-				completedURLs <- url
-			}(url)
+	// 导出当前批量截图结果（含指纹标签和审阅记录），支持 format=json 或 csv，
+	// 可按 triage、tag、category、status 筛选
+	mux.HandleFunc("/export-results", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
 
-		// 启动一个goroutine监听完成的URL并更新进度
-		go func() {
-			var localProcessedCount = 0
-			processedURLs := make(map[string]bool) // 用于跟踪已处理的原始URL
-			for originalUrl := range completedURLs {
-				// 对原始URL进行计数，确保每个URL都被正确计数
-				if !processedURLs[originalUrl] {
-					processedURLs[originalUrl] = true
-					localProcessedCount++
-				}
-				progress := int(float64(localProcessedCount) / float64(totalCount) * 100)
-				status := fmt.Sprintf("已完成 %d/%d 个URL的截图", localProcessedCount, totalCount)
-
-				// 发送进度更新和已完成的URL信息（标准化后的URL）
-				progressData := map[string]interface{}{
-					"progress":     progress,
-					"status":       status,
-					"completedUrl": normalizeURL(originalUrl),
-				}
-				jsonData, _ := json.Marshal(progressData)
-				fmt.Fprintf(w, "data: %s\n\n", jsonData)
+		filter, err := filterFromQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		batchMutex.Lock()
+		results := sortedResults(batchResults)
+		runID := batchRunID
+		batchMutex.Unlock()
+		results = filterResults(results, filter)
 
-				if flusher, ok := w.(http.Flusher); ok {
-					flusher.Flush()
-				}
+		if r.URL.Query().Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"webcut-%s.csv\"", runID))
+			err = writeResultsCSV(w, results)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"webcut-%s.json\"", runID))
+			err = writeResultsJSON(w, results)
+		}
+		if err != nil {
+			fmt.Printf("导出截图结果失败: %v\n", err)
+		}
+	})
+
+	// 分页查询当前批量任务的结果元数据（不含图片，附带审阅记录），url 参数可查询单个URL的结果
+	mux.HandleFunc("/api/results", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		filter, err := filterFromQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		offset, limit := pageParams(r)
+		batchMutex.Lock()
+		var results []*CaptureResult
+		if target := r.URL.Query().Get("url"); target != "" {
+			if result := batchResults[normalizeURL(target)]; result != nil {
+				results = append(results, result)
 			}
-		}()
+		} else {
+			results = sortedResults(batchResults)
+		}
+		runID := batchRunID
+		batchMutex.Unlock()
+		results = filterResults(results, filter)
 
-		// 等待所有截图任务完成
-		wg.Wait()
-		close(completedURLs) // 确保所有进度更新都已处理
+		total := len(results)
+		page := results[min(offset, total):min(offset+limit, total)]
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"runId":   runID,
+			"total":   total,
+			"offset":  offset,
+			"limit":   limit,
+			"results": page,
+		})
+	})
 
-		// 等待一小段时间，确保最后一个进度更新已经发送
-		time.Sleep(100 * time.Millisecond)
+	// 按结果ID返回截图原图
+	mux.HandleFunc("/api/images/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-		// 发送完成通知，确保包含正确的进度文本
-		completeData := map[string]interface{}{"progress": 100,
-			"status":       fmt.Sprintf("已完成 %d/%d 个URL的截图", totalCount, totalCount),
-			"allCompleted": true,
+		result := findResult(strings.TrimPrefix(r.URL.Path, "/api/images/"))
+		if result == nil {
+			http.NotFound(w, r)
+			return
+		}
+		data := resultImage(result)
+		if data == nil {
+			http.NotFound(w, r)
+			return
 		}
-		jsonData, _ := json.Marshal(completeData)
-		fmt.Fprintf(w, "data: %s\n\n", jsonData)
-		if flusher, ok := w.(http.Flusher); ok {
-			flusher.Flush()
+		serveImage(w, r, data)
+	})
+
+	// 按结果ID返回缩略图，首次请求时生成并缓存
+	mux.HandleFunc("/thumb/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
 
-		fmt.Println("批量截图任务完成")
+		id := strings.TrimPrefix(r.URL.Path, "/thumb/")
+		result := findResult(id)
+		if result == nil {
+			http.NotFound(w, r)
+			return
+		}
+		data := resultImage(result)
+		if data == nil {
+			http.NotFound(w, r)
+			return
+		}
+		thumb, err := thumbs.get(id, data)
+		if err != nil {
+			fmt.Printf("生成缩略图失败: %v\n", err)
+			http.Error(w, "生成缩略图失败", http.StatusInternalServerError)
+			return
+		}
+		serveImage(w, r, thumb)
 	})
 
-	// 获取批量截图结果
-	http.HandleFunc("/get-batch-screenshots", func(w http.ResponseWriter, r *http.Request) {
+	// 按截图外观相似度分组，distance 为汉明距离阈值（0-64）
+	mux.HandleFunc("/clusters", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// 获取批量截图结果
-		batchMutex.Lock()
-		// 将二进制数据转换为base64
-		base64Screenshots := make(map[string]string)
-		for url, imgData := range batchScreenshots {
-			base64Screenshots[url] = base64.StdEncoding.EncodeToString(imgData)
+		distance := defaultClusterDistance
+		if value := r.URL.Query().Get("distance"); value != "" {
+			d, err := strconv.Atoi(value)
+			if err != nil || d < 0 || d > 64 {
+				http.Error(w, "distance 必须是 0-64 之间的整数", http.StatusBadRequest)
+				return
+			}
+			distance = d
 		}
+
+		batchMutex.Lock()
+		results := sortedResults(batchResults)
 		batchMutex.Unlock()
 
-		// 返回base64编码的截图结果
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"screenshots": base64Screenshots,
+			"distance": distance,
+			"clusters": clusterResults(results, distance),
 		})
-
-		fmt.Println("返回批量截图结果")
 	})
 
+	// 版本化REST接口，供自动化脚本提交任务和查询结果，接口说明见 /api/v1/openapi.json
+	mux.Handle("/api/v1/", newAPIHandler())
+
+	// 提供运行目录中的截图、附件和报告
+	mux.Handle("/runs/", runFilesHandler())
+
 	// 在后台启动服务器
-	go http.Serve(listener, nil)
+	go serveHTTP(server, listener)
 
-	return addr
+	return server, addr, nil
 }
 
 // 全局变量用于存储浏览器池 - 增加池大小以提高可靠性
+const browserPoolSize = 10
+
 var (
-	browserPool      = make(chan context.Context, 10) // 浏览器池，大小为10
+	browserPool      chan *pooledBrowser // 浏览器池，重置时整体替换，读写都需持有 browserPoolMutex
 	browserPoolMutex sync.Mutex
-	allocatorCancels []context.CancelFunc // 存储所有执行分配器的cancel函数
 )
 
-// initBrowserPool 初始化浏览器池
-func initBrowserPool() {
+// pooledBrowser 池中的一个浏览器实例及其执行分配器的cancel函数
+type pooledBrowser struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newBrowserPool 创建一组新的浏览器实例，浏览器进程在第一次使用时才启动
+func newBrowserPool() chan *pooledBrowser {
 	// 使用更通用的选项，增强HTTPS和TLS支持，添加跳转处理能力
 	// 添加自定义User-Agent以提高截图成功率，避免被识别为爬虫
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
@@ -958,61 +2587,73 @@ func initBrowserPool() {
 		chromedp.Flag("disable-popup-blocking", true), // 禁用弹窗拦截
 	)
 
-	fmt.Println("开始初始化浏览器池...")
-
-	// 存储所有执行分配器的cancel函数，在程序退出时统一清理
-	allocatorCancels = make([]context.CancelFunc, 0, cap(browserPool))
-
-	// 创建5个浏览器实例放入池中
-	for i := 0; i < cap(browserPool); i++ {
+	pool := make(chan *pooledBrowser, browserPoolSize)
+	for i := 0; i < browserPoolSize; i++ {
 		// 创建执行分配器
 		allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-		allocatorCancels = append(allocatorCancels, cancel)
 
 		// 创建新的上下文
 		ctx, _ := chromedp.NewContext(allocCtx)
 
-		browserPool <- ctx
+		pool <- &pooledBrowser{ctx: ctx, cancel: cancel}
 	}
+	return pool
+}
 
-	fmt.Println("浏览器池初始化完成，共", cap(browserPool), "个浏览器实例")
+// initBrowserPool 初始化浏览器池
+func initBrowserPool() {
+	fmt.Println("开始初始化浏览器池...")
+	pool := newBrowserPool()
+	browserPoolMutex.Lock()
+	browserPool = pool
+	browserPoolMutex.Unlock()
+	fmt.Println("浏览器池初始化完成，共", browserPoolSize, "个浏览器实例")
 }
 
 // getBrowserContext 从池中获取一个浏览器上下文
-// 返回原始池上下文和释放函数，不修改上下文本身
+// 返回原始池上下文和释放函数，不修改上下文本身。等待期间浏览器池被重置时改从新池中获取
 func getBrowserContext() (context.Context, func()) {
-	ctx := <-browserPool
-	// 返回原始上下文和释放函数
-	return ctx, func() {
-		// 确保上下文被放回池中，即使有panic发生
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Printf("恢复浏览器上下文时发生panic: %v\n", r)
-			}
-		}()
-		browserPool <- ctx
+	for {
+		browserPoolMutex.Lock()
+		pool := browserPool
+		browserPoolMutex.Unlock()
+
+		browser, ok := <-pool
+		if !ok {
+			continue
+		}
+		var once sync.Once
+		return browser.ctx, func() {
+			once.Do(func() {
+				browserPoolMutex.Lock()
+				defer browserPoolMutex.Unlock()
+				if pool == browserPool {
+					pool <- browser
+					return
+				}
+				// 使用期间浏览器池已被重置，旧的浏览器实例用完即关闭
+				browser.cancel()
+			})
+		}
 	}
 }
 
 // resetBrowserPool 重置浏览器池，创建新的浏览器实例
-// 当切换URL列表或浏览器池出现异常时调用
+// 当切换URL列表或浏览器池出现异常时调用。正在使用的旧实例（如同时进行的单个截图）不受影响，归还时再关闭
 func resetBrowserPool() {
 	fmt.Println("开始重置浏览器池...")
+	pool := newBrowserPool()
 
-	// 清空并关闭当前浏览器池
-	close(browserPool)
+	browserPoolMutex.Lock()
+	old := browserPool
+	browserPool = pool
+	// 关闭旧池：等待中的获取方转到新池，空闲的旧实例在下面逐个关闭
+	close(old)
+	browserPoolMutex.Unlock()
 
-	// 清理所有执行分配器
-	for _, cancel := range allocatorCancels {
-		cancel()
+	for browser := range old {
+		browser.cancel()
 	}
-
-	// 创建新的浏览器池和分配器列表
-	browserPool = make(chan context.Context, 10)
-	allocatorCancels = make([]context.CancelFunc, 0, cap(browserPool))
-
-	// 重新初始化浏览器池
-	initBrowserPool()
 	fmt.Println("浏览器池重置完成")
 }
 
@@ -1042,43 +2683,108 @@ func normalizeURL(urlStr string) string {
 }
 
 // captureScreenshot 捕获指定URL的截图（使用浏览器池）- 增强版支持复杂页面和防爬虫检测
-func captureScreenshot(url string, fullPage bool) ([]byte, error) {
+// 支持按元素选择器或裁剪区域截图，找不到元素时依次回退到裁剪区域和整页/视口截图并在结果中记录
+func captureScreenshot(url string, opts CaptureOptions) (*CaptureResult, error) {
 	// 标准化URL格式，确保一致性
 	url = normalizeURL(url)
 
+	// 按URL匹配规则确定实际生效的截图选项
+	opts = opts.resolveFor(url)
+
 	// 存储截图结果
 	var buf []byte
 	var lastErr error
-	maxRetries := 1 // 总共2次尝试，减少重试次数提高速度
+	var lastResult *CaptureResult
+	maxRetries := opts.Retry.retries()
+
+	// 空白页重试时额外等待的时间，以及最近一次需要重试的截图结果（空白页或网关错误，重试仍失败时使用）
+	var blankWait time.Duration
+	var pendingResult *CaptureResult
 
 	// 判断是否为需要特殊处理的URL（可能需要更长加载时间）
 	needsSpecialHandling := needsLongerTimeout(url)
+	viewportWidth, viewportHeight := opts.Viewport.size()
+
+	// 与截图并行获取HTTPS证书（浏览器忽略证书错误，证书异常需要单独记录）
+	certFetch := startCertificateFetch(url)
+
+	// 截图成功后补充证书、favicon、指纹和页面分类等信息
+	finish := func(result *CaptureResult) *CaptureResult {
+		result.PerceptualHash = perceptualHash(result.Image)
+		certFetch.apply(result)
+		fetchFavicon(result, opts)
+		result.Technologies = identifyTechnologies(fingerprintInputFor(result))
+		classifyPage(result)
+		return result
+	}
 
 	// 尝试多次截图
 	for attempt := 1; attempt <= maxRetries+1; attempt++ {
+		// 目标在重试等待期间被取消或跳过时不再尝试
+		if cause := opts.canceled(); cause != nil {
+			lastErr = cause
+			break
+		}
+
 		// 每次尝试都获取新的浏览器上下文，避免之前的错误影响
-		baseCtx, release := getBrowserContext()
+		poolCtx, release := getBrowserContext()
+		// 带认证信息时使用独立的浏览器上下文，Cookie和请求头不会带到池中标签页的下一次截图
+		baseCtx, closeAuthContext, err := opts.Auth.authContext(poolCtx)
+		if err != nil {
+			release()
+			lastErr = &CaptureError{Kind: ErrorBrowserCrash, Err: err}
+			fmt.Printf("URL %s 创建独立浏览器上下文失败: %v\n", url, err)
+			if attempt <= maxRetries {
+				opts.wait(opts.Retry.delay(attempt))
+			}
+			continue
+		}
 
 		// 计算合理的超时时间，与当前尝试次数和URL特性相关联
 		baseTimeout := 15 * time.Second
 		if needsSpecialHandling {
 			baseTimeout = 25 * time.Second // 为特殊URL增加基础超时时间
 		}
-		timeoutDuration := time.Duration(int(baseTimeout.Seconds())+(attempt-1)*5) * time.Second
+		timeoutDuration := baseTimeout + time.Duration(attempt-1)*opts.Retry.timeoutStep() + blankWait + opts.Wait.delay()
 
 		// 为每次尝试创建新的超时上下文
 		ctxWithTimeout, cancel := context.WithTimeout(baseCtx, timeoutDuration)
+		// 取消或跳过目标时立即中止当前尝试
+		stopCancel := context.AfterFunc(opts.context(), cancel)
 
 		// 存储最终URL和页面信息
 		var finalURL string
 		var navigationCompleted bool
+		result := &CaptureResult{URL: url, Attempts: attempt, CapturedAt: time.Now()}
+		buf = nil
+		// 当前所处阶段，用于区分导航、等待和截图超时
+		stage := stageNavigate
+
+		// 收集控制台输出、JS异常和失败请求，监听器随本次尝试的上下文取消而移除
+		pageEvents := newPageEventCollector()
+		chromedp.ListenTarget(ctxWithTimeout, pageEvents.listen)
+
+		// 按需记录网络请求
+		var har *harRecorder
+		if opts.SaveHAR {
+			har = newHARRecorder(opts)
+			chromedp.ListenTarget(ctxWithTimeout, har.listen)
+		}
 
 		// 运行任务：导航到URL并等待页面完全加载后再截图
-		err := chromedp.Run(ctxWithTimeout,
+		err = chromedp.Run(ctxWithTimeout,
 			// 设置页面加载策略
-			chromedp.EmulateViewport(1920, 1080),
+			chromedp.EmulateViewport(viewportWidth, viewportHeight),
+			// 设置认证Cookie和请求头
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				return opts.Auth.apply(ctx, url)
+			}),
 			// 导航到URL
-			chromedp.Navigate(url),
+			chromedp.Navigate(opts.Auth.navigateURL(url)),
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				stage = stageWait
+				return nil
+			}),
 			// 等待网络空闲，确保大部分资源已加载
 			chromedp.WaitNotPresent(`.loading`),
 			// 等待页面加载完成，包括跳转
@@ -1115,7 +2821,9 @@ func captureScreenshot(url string, fullPage bool) ([]byte, error) {
 						previousURL = currentURL
 					}
 
-					time.Sleep(checkInterval)
+					if err := waitContext(ctx, checkInterval); err != nil {
+						return err
+					}
 				}
 
 				// 特别处理：如果页面有加载动画，额外等待但不超时
@@ -1123,16 +2831,25 @@ func captureScreenshot(url string, fullPage bool) ([]byte, error) {
 				if needsSpecialHandling {
 					animationWaitTime = 4 * time.Second // 为特殊URL增加加载动画等待时间
 				}
-				time.Sleep(animationWaitTime)
+				if err := waitContext(ctx, animationWaitTime); err != nil {
+					return err
+				}
 
 				// 等待JavaScript执行完成
 				jsWaitTime := 1 * time.Second
 				if needsSpecialHandling {
 					jsWaitTime = 2 * time.Second // 为特殊URL增加JavaScript等待时间
 				}
-				time.Sleep(jsWaitTime)
-
-				return nil
+				return waitContext(ctx, jsWaitTime+blankWait)
+			}),
+			// 按选项等待指定元素出现，再额外等待
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				if opts.Wait.Selector != "" {
+					if err := chromedp.WaitVisible(opts.Wait.Selector, chromedp.ByQuery).Do(ctx); err != nil {
+						return err
+					}
+				}
+				return waitContext(ctx, opts.Wait.delay())
 			}),
 			// 额外的等待时间让页面完全渲染，但限制在总超时内
 			chromedp.Sleep(500*time.Millisecond), // 增加渲染等待时间
@@ -1146,76 +2863,136 @@ func captureScreenshot(url string, fullPage bool) ([]byte, error) {
 				if needsSpecialHandling {
 					scrollWaitTime = 500 * time.Millisecond // 为特殊URL增加滚动等待时间
 				}
-				time.Sleep(scrollWaitTime)
+				if err := waitContext(ctx, scrollWaitTime); err != nil {
+					return err
+				}
 				// 再滚动回顶部，确保从顶部开始截图
 				if err := chromedp.Evaluate(`window.scrollTo({top: 0, behavior: 'auto'})`, nil).Do(ctx); err != nil {
 					// 静默忽略错误，继续执行
 				}
-				time.Sleep(scrollWaitTime)
-				return nil
+				return waitContext(ctx, scrollWaitTime)
 			}),
-			// 截图操作 - 提高质量并改进错误处理
+			// 截图操作 - 依次尝试元素、裁剪区域，都不可用时回退到整页/视口
 			chromedp.ActionFunc(func(ctx context.Context) error {
-				if fullPage {
-					return chromedp.FullScreenshot(&buf, 90).Do(ctx) // 提高质量
-				} else {
-					return chromedp.CaptureScreenshot(&buf).Do(ctx)
+				stage = stageScreenshot
+				return takeScreenshot(ctx, opts, &buf, result)
+			}),
+			// 保存渲染后的DOM、可见文本和标题
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				return capturePageContent(ctx, result)
+			}),
+			// 按需保存PDF和MHTML，复用同一个页面会话
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				return captureArtifacts(ctx, opts, result)
+			}),
+			// 按需读取HAR响应体
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				if har == nil {
+					return nil
 				}
+				return har.fetchBodies(ctx)
 			}),
 		)
 
 		// 立即取消当前上下文，避免资源泄漏
+		stopCancel()
 		cancel()
-		// 释放浏览器上下文，立即放回池中
+		// 关闭认证用的浏览器上下文，释放浏览器上下文，立即放回池中
+		closeAuthContext()
 		release()
 
+		// 失败的尝试同样保留页面事件和HAR，便于排查浏览器实际发出的请求
+		pageEvents.apply(result)
+		if har != nil {
+			title := result.Title
+			if title == "" {
+				title = url
+			}
+			result.HAR = har.marshal(title)
+		}
+		lastResult = result
+
 		// 即使有错误，也检查是否有成功捕获的截图
 		if err == nil || len(buf) > 0 {
 			if len(buf) > 0 {
 				// 截图成功
 				if navigationCompleted && len(finalURL) > 0 && finalURL != url {
 					fmt.Printf("成功处理跳转：从 %s -> %s\n", url, finalURL)
+					result.FinalURL = finalURL
 				}
-				return buf, nil
+				if result.Fallback != "" {
+					fmt.Printf("URL %s 回退为%s截图: %s\n", url, captureModeLabels[result.Mode], result.Fallback)
+				}
+				result.Image = buf
+				result.Blank, result.BlankReason = detectBlank(buf, result.TextSize)
+				if result.StatusCode >= 400 {
+					result.ErrorKind = ErrorHTTPStatus
+				}
+				switch {
+				case attempt > maxRetries:
+					return finish(result), nil
+				case result.Blank && opts.RetryBlank:
+					// 空白页视为可重试的结果，延长等待JS渲染后重新截图
+					fmt.Printf("URL %s 截图为空白页（%s），延长等待后重试\n", url, result.BlankReason)
+					blankWait += blankRetryWait
+				case isGatewayStatus(result.StatusCode):
+					// 网关错误通常是后端暂时不可用，稍后重试
+					fmt.Printf("URL %s 返回 HTTP %d，稍后重试\n", url, result.StatusCode)
+				default:
+					return finish(result), nil
+				}
+				pendingResult = result
 			} else {
 				// 没有捕获到截图数据
-				lastErr = fmt.Errorf("截图数据为空")
+				lastErr = &CaptureError{Kind: ErrorEmpty, Err: fmt.Errorf("截图数据为空")}
 			}
+		} else if cause := opts.canceled(); cause != nil {
+			fmt.Printf("URL %s %s\n", url, errorKindOf(cause).Label())
+			lastErr = cause
+			break
 		} else {
-			lastErr = err
+			kind := classifyError(err, stage)
+			if pageEvents.targetCrashed() {
+				kind = ErrorBrowserCrash
+			}
+			lastErr = &CaptureError{Kind: kind, Err: err}
+			// DNS失败、连接被拒绝、证书错误等重试也不会成功，可重试的类型由重试策略决定
+			if !opts.Retry.shouldRetry(kind) {
+				fmt.Printf("URL %s 截图失败（%s），不再重试\n", url, kind.Label())
+				break
+			}
 		}
 
-		// 如果不是最后一次尝试，等待一段时间后再重试
+		// 如果不是最后一次尝试，按指数退避等待一段时间后再重试
 		if attempt <= maxRetries {
-			// 增加等待时间以提高重试成功率，使用递增等待策略
-			waitTime := time.Duration(attempt*1) * time.Second
+			waitTime := opts.Retry.delay(attempt)
 			if needsSpecialHandling {
-				waitTime = time.Duration(attempt*2) * time.Second // 为特殊URL增加重试等待时间
+				waitTime *= 2 // 为特殊URL增加重试等待时间
 			}
-			fmt.Printf("[尝试 #%d] 等待%d秒后重试...\n", attempt, waitTime/time.Second)
-			time.Sleep(waitTime)
+			fmt.Printf("[尝试 #%d] 等待%.1f秒后重试...\n", attempt, waitTime.Seconds())
+			opts.wait(waitTime)
 		}
 	}
 
-	// 所有尝试都失败
-	return nil, fmt.Errorf("执行截图任务失败（已尝试 %d 次）: %v\n可能原因: 网络问题、页面加载失败或防爬虫限制", maxRetries+1, lastErr)
-}
+	// 重试后仍未得到正常截图时，接受之前的空白页或网关错误截图
+	if pendingResult != nil {
+		return finish(pendingResult), nil
+	}
 
-// 创建截图失败的占位图
-func createErrorPlaceholder(width, height int, url string) []byte {
-	// 创建一个简单的SVG作为占位图
-	svg := fmt.Sprintf(`<svg width="%d" height="%d" xmlns="http://www.w3.org/2000/svg">
-  <rect width="%d" height="%d" fill="#f5f5f5"/>
-  <rect x="%d" y="%d" width="200" height="150" fill="#e0e0e0" rx="4"/>
-  <text x="%d" y="%d" font-family="Arial" font-size="24" fill="#666666" text-anchor="middle">截图失败</text>
-  <text x="%d" y="%d" font-family="Arial" font-size="12" fill="#999999" text-anchor="middle">%s</text>
-</svg>`,
-		width, height,
-		width, height,
-		(width-200)/2, (height-150)/2,
-		width/2, (height/2)+10,
-		width/2, (height/2)+30, url)
-	return []byte(svg)
+	// 所有尝试都失败，返回最后一次尝试的结果（包含HAR等诊断信息）
+	kind := errorKindOf(lastErr)
+	err := lastErr
+	switch {
+	case lastResult == nil:
+		// 第一次尝试前就被取消
+		lastResult = &CaptureResult{URL: url, CapturedAt: time.Now()}
+	case kind != ErrorCanceled && kind != ErrorSkipped:
+		err = &CaptureError{Kind: kind, Err: fmt.Errorf("已尝试 %d 次: %v", lastResult.Attempts, errors.Unwrap(lastErr))}
+	}
+	lastResult.Error = err.Error()
+	lastResult.ErrorKind = kind
+	certFetch.apply(lastResult)
+	return lastResult, err
 }
 
 // needsLongerTimeout 判断URL是否需要更长的超时时间处理
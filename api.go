@@ -0,0 +1,564 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// API错误码
+const (
+	apiErrUnauthorized     = "unauthorized"
+	apiErrForbidden        = "forbidden"
+	apiErrNotFound         = "not_found"
+	apiErrMethodNotAllowed = "method_not_allowed"
+	apiErrInvalidRequest   = "invalid_request"
+	apiErrJobRunning       = "job_running"
+	apiErrJobFinished      = "job_finished"
+	apiErrNoTargets        = "no_targets"
+	apiErrInternal         = "internal_error"
+)
+
+// APIError /api/v1 统一的错误响应
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError 写入 {"error": {"code": ..., "message": ...}} 格式的错误响应
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]APIError{"error": {Code: code, Message: message}})
+}
+
+// allowMethods 检查请求方法，不允许时返回405错误并返回false
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method || (method == "GET" && r.Method == "HEAD") {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeAPIError(w, http.StatusMethodNotAllowed, apiErrMethodNotAllowed, fmt.Sprintf("不支持 %s 方法", r.Method))
+	return false
+}
+
+// decodeJSON 解析JSON请求体，失败时返回400错误并返回false
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, fmt.Sprintf("请求体不是有效的JSON: %v", err))
+		return false
+	}
+	return true
+}
+
+// findResult 按ID查找截图结果：先查当前任务，再查对应运行目录的结果记录
+func findResult(id string) *CaptureResult {
+	batchMutex.Lock()
+	result := batchResultsByID[id]
+	batchMutex.Unlock()
+	if result != nil {
+		return result
+	}
+	results, err := loadRunResults(resultRunID(id))
+	if err != nil {
+		return nil
+	}
+	for _, result := range results {
+		if result.ID == id {
+			return result
+		}
+	}
+	return nil
+}
+
+// resultImage 返回结果的截图数据，不在内存中（以前的运行）时从运行目录读取
+func resultImage(result *CaptureResult) []byte {
+	if len(result.Image) > 0 {
+		return result.Image
+	}
+	name, ok := result.Artifacts[artifactScreenshot]
+	if !ok {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(runsDir, resultRunID(result.ID), name))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// jobResults 返回任务的截图结果，当前任务取内存中的结果，以前的运行读取结果记录
+func jobResults(id string) ([]*CaptureResult, error) {
+	batchMutex.Lock()
+	if id == batchRunID {
+		results := sortedResults(batchResults)
+		batchMutex.Unlock()
+		return results, nil
+	}
+	batchMutex.Unlock()
+	return loadRunResults(id)
+}
+
+// newAPIHandler 创建 /api/v1 接口，使用独立的ServeMux，所有响应（包括错误）均为JSON
+func newAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	// 目标列表，与界面加载的URL列表相同
+	mux.HandleFunc("/api/v1/targets", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET", "PUT") {
+			return
+		}
+		if r.Method == "PUT" {
+			var req struct {
+				Targets []string `json:"targets"`
+			}
+			if !decodeJSON(w, r, &req) {
+				return
+			}
+			targets := make([]string, 0, len(req.Targets))
+			for _, target := range req.Targets {
+				if target = strings.TrimSpace(target); target != "" {
+					targets = append(targets, target)
+				}
+			}
+			urlListMutex.Lock()
+			urlList = targets
+			urlListMutex.Unlock()
+			fmt.Printf("通过API加载URL列表，共 %d 个URL\n", len(targets))
+		}
+
+		urlListMutex.Lock()
+		targets := append([]string{}, urlList...)
+		urlListMutex.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"targets": targets})
+	})
+
+	// 提交和列出批量任务，任务在后台执行，通过 GET /api/v1/jobs/{id} 轮询状态
+	mux.HandleFunc("/api/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET", "POST") {
+			return
+		}
+		if r.Method == "GET" {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": listJobs()})
+			return
+		}
+
+		var req struct {
+			Targets []string        `json:"targets"` // 为空时使用已加载的目标列表
+			Options *CaptureOptions `json:"options"` // 为空时使用设置中的默认选项
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		targets := req.Targets
+		if len(targets) == 0 {
+			urlListMutex.Lock()
+			targets = append([]string{}, urlList...)
+			urlListMutex.Unlock()
+		}
+		opts := currentSettings().Options
+		if req.Options != nil {
+			opts = *req.Options
+		}
+
+		job, err := startJob(targets, opts, requestUser(r))
+		switch {
+		case err == errBatchRunning:
+			writeAPIError(w, http.StatusConflict, apiErrJobRunning, err.Error())
+			return
+		case err == errNoTargets:
+			writeAPIError(w, http.StatusBadRequest, apiErrNoTargets, err.Error())
+			return
+		case errors.Is(err, errInvalidOptions):
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, err.Error())
+			return
+		case err != nil:
+			writeAPIError(w, http.StatusInternalServerError, apiErrInternal, err.Error())
+			return
+		}
+		go job.execute()
+
+		w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job.snapshot())
+	})
+
+	// 截图单个URL，结果保存为新的运行，截图失败时同样返回201和失败结果
+	mux.HandleFunc("/api/v1/captures", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "POST") {
+			return
+		}
+		var req struct {
+			URL     string          `json:"url"`
+			Options *CaptureOptions `json:"options"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		opts := currentSettings().Options
+		if req.Options != nil {
+			opts = *req.Options
+		}
+		capture, err := captureSingle(req.URL, opts)
+		if capture == nil {
+			if errors.Is(err, errNoURL) || errors.Is(err, errInvalidOptions) {
+				writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, err.Error())
+			} else {
+				writeAPIError(w, http.StatusInternalServerError, apiErrInternal, err.Error())
+			}
+			return
+		}
+		w.Header().Set("Location", "/api/v1/results/"+capture.Result.ID)
+		writeJSON(w, http.StatusCreated, capture)
+	})
+
+	mux.HandleFunc("/api/v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		job := findJob(r.PathValue("id"))
+		if job == nil {
+			writeAPIError(w, http.StatusNotFound, apiErrNotFound, "任务不存在")
+			return
+		}
+		writeJSON(w, http.StatusOK, job.snapshot())
+	})
+
+	// 订阅任务事件（SSE），支持 Last-Event-ID 或 lastEventId 参数从断点补发，界面刷新后可重新接入运行中的任务
+	mux.HandleFunc("/api/v1/jobs/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		job := findJob(r.PathValue("id"))
+		if job == nil {
+			writeAPIError(w, http.StatusNotFound, apiErrNotFound, "任务不存在")
+			return
+		}
+		streamJobEvents(w, r, job)
+	})
+
+	// 任务的WebSocket控制通道，推送与 /events 相同的事件并接收控制命令
+	mux.HandleFunc("/api/v1/jobs/{id}/ws", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		job := findJob(r.PathValue("id"))
+		if job == nil {
+			writeAPIError(w, http.StatusNotFound, apiErrNotFound, "任务不存在")
+			return
+		}
+		serveJobSocket(w, r, job)
+	})
+
+	// 发送单条控制命令：取消或跳过目标、调整优先级和并发数、暂停或取消任务
+	mux.HandleFunc("/api/v1/jobs/{id}/control", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "POST") {
+			return
+		}
+		job := findJob(r.PathValue("id"))
+		if job == nil {
+			writeAPIError(w, http.StatusNotFound, apiErrNotFound, "任务不存在")
+			return
+		}
+		var cmd JobCommand
+		if !decodeJSON(w, r, &cmd) {
+			return
+		}
+		if err := job.control(cmd, requestUser(r)); err != nil {
+			if errors.Is(err, errJobFinished) {
+				writeAPIError(w, http.StatusConflict, apiErrJobFinished, err.Error())
+			} else {
+				writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, err.Error())
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, job.snapshot())
+	})
+
+	// 分页查询任务的截图结果（附带审阅记录），url 参数可查询单个URL的结果，
+	// triage、tag、category、status 参数按审阅状态、标签、分类和截图状态筛选
+	mux.HandleFunc("/api/v1/jobs/{id}/results", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		id := r.PathValue("id")
+		results, err := jobResults(id)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				writeAPIError(w, http.StatusNotFound, apiErrNotFound, "任务不存在或尚未生成结果记录")
+			} else {
+				writeAPIError(w, http.StatusInternalServerError, apiErrInternal, err.Error())
+			}
+			return
+		}
+		if target := r.URL.Query().Get("url"); target != "" {
+			var matched []*CaptureResult
+			for _, result := range results {
+				if normalizeURL(result.URL) == normalizeURL(target) {
+					matched = append(matched, result)
+				}
+			}
+			results = matched
+		}
+		filter, err := filterFromQuery(r)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, err.Error())
+			return
+		}
+		results = filterResults(results, filter)
+
+		offset, limit := pageParams(r)
+		total := len(results)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"jobId":   id,
+			"total":   total,
+			"offset":  offset,
+			"limit":   limit,
+			"results": results[min(offset, total):min(offset+limit, total)],
+		})
+	})
+
+	// 导出任务的截图结果（含审阅记录），支持 format=json 或 csv，筛选参数与结果查询相同
+	mux.HandleFunc("/api/v1/jobs/{id}/export", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		id := r.PathValue("id")
+		results, err := jobResults(id)
+		if err != nil {
+			writeAPIError(w, http.StatusNotFound, apiErrNotFound, "任务不存在或尚未生成结果记录")
+			return
+		}
+		filter, err := filterFromQuery(r)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, err.Error())
+			return
+		}
+		results = filterResults(results, filter)
+		switch r.URL.Query().Get("format") {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"webcut-%s.csv\"", id))
+			err = writeResultsCSV(w, results)
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"webcut-%s.json\"", id))
+			err = writeResultsJSON(w, results)
+		default:
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, "format 只支持 json 或 csv")
+			return
+		}
+		if err != nil {
+			fmt.Printf("导出截图结果失败: %v\n", err)
+		}
+	})
+
+	mux.HandleFunc("/api/v1/results/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		result := findResult(r.PathValue("id"))
+		if result == nil {
+			writeAPIError(w, http.StatusNotFound, apiErrNotFound, "结果不存在")
+			return
+		}
+		writeJSON(w, http.StatusOK, withTriage([]*CaptureResult{result})[0])
+	})
+
+	// 查看和修改结果的审阅记录：状态、标签和备注，保存在结果所属的运行目录中
+	mux.HandleFunc("/api/v1/results/{id}/triage", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET", "PUT") {
+			return
+		}
+		id := r.PathValue("id")
+		if findResult(id) == nil {
+			writeAPIError(w, http.StatusNotFound, apiErrNotFound, "结果不存在")
+			return
+		}
+		if r.Method != "PUT" {
+			writeJSON(w, http.StatusOK, triages.get(id))
+			return
+		}
+		var update TriageUpdate
+		if !decodeJSON(w, r, &update) {
+			return
+		}
+		t, err := triages.update(id, update, requestUser(r))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, t)
+	})
+
+	// 下载结果的附件：screenshot、thumbnail、pdf、mhtml、har、dom、text、favicon
+	mux.HandleFunc("/api/v1/results/{id}/artifacts/{kind}", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		id, kind := r.PathValue("id"), r.PathValue("kind")
+		result := findResult(id)
+		if result == nil {
+			writeAPIError(w, http.StatusNotFound, apiErrNotFound, "结果不存在")
+			return
+		}
+
+		switch kind {
+		case artifactScreenshot, "thumbnail":
+			data := resultImage(result)
+			if data == nil {
+				writeAPIError(w, http.StatusNotFound, apiErrNotFound, "该结果没有截图")
+				return
+			}
+			if kind == "thumbnail" {
+				thumb, err := thumbs.get(id, data)
+				if err != nil {
+					writeAPIError(w, http.StatusInternalServerError, apiErrInternal, fmt.Sprintf("生成缩略图失败: %v", err))
+					return
+				}
+				data = thumb
+			}
+			serveImage(w, r, data)
+		default:
+			name, ok := result.Artifacts[kind]
+			if !ok {
+				writeAPIError(w, http.StatusNotFound, apiErrNotFound, fmt.Sprintf("该结果没有 %s 附件", kind))
+				return
+			}
+			setUntrustedHeaders(w, name)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+			http.ServeFile(w, r, filepath.Join(runsDir, resultRunID(id), name))
+		}
+	})
+
+	// 全文检索所有运行的截图结果（网址、标题、响应头、产品标签和页面文本），run 参数可限定运行（逗号分隔），
+	// snippet=0 时不生成摘要（只需要命中的结果ID时）
+	mux.HandleFunc("/api/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		query := r.URL.Query().Get("q")
+		runs := make(map[string]bool)
+		for _, id := range strings.Split(r.URL.Query().Get("run"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				runs[id] = true
+			}
+		}
+		hits, err := pageIndex.search(query, runs)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, err.Error())
+			return
+		}
+
+		offset, limit := pageParams(r)
+		total := len(hits)
+		page := hits[min(offset, total):min(offset+limit, total)]
+		if r.URL.Query().Get("snippet") != "0" {
+			for i := range page {
+				page[i] = pageIndex.withSnippet(page[i])
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"query":  query,
+			"total":  total,
+			"offset": offset,
+			"limit":  limit,
+			"hits":   page,
+		})
+	})
+
+	// 已完成的运行，供选择对比的两次运行
+	mux.HandleFunc("/api/v1/runs", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		runs := listRuns()
+		if runs == nil {
+			runs = []RunInfo{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"runs": runs})
+	})
+
+	// 对比两次运行：base 为旧运行，target 为新运行，threshold 为视为外观变化的像素占比（百分比）
+	mux.HandleFunc("/api/v1/compare", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		query := r.URL.Query()
+		base, target := query.Get("base"), query.Get("target")
+		if base == "" || target == "" {
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, "需要 base 和 target 两个运行编号")
+			return
+		}
+		threshold := defaultDiffThreshold
+		if s := query.Get("threshold"); s != "" {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || v < 0 || v > 100 {
+				writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, "threshold 必须是 0 到 100 之间的百分比")
+				return
+			}
+			threshold = v
+		}
+		comparison, err := compareRuns(base, target, threshold)
+		switch {
+		case errors.Is(err, errSameRun):
+			writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, err.Error())
+		case errors.Is(err, os.ErrNotExist):
+			writeAPIError(w, http.StatusNotFound, apiErrNotFound, "运行不存在或尚未完成")
+		case err != nil:
+			writeAPIError(w, http.StatusInternalServerError, apiErrInternal, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, comparison)
+		}
+	})
+
+	mux.HandleFunc("/api/v1/settings", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET", "PUT") {
+			return
+		}
+		if r.Method == "PUT" {
+			// 在当前设置的副本上解析（未提供的字段保持不变），认证信息中的占位符保留已保存的值
+			stored := currentSettings()
+			s := stored.clone()
+			if !decodeJSON(w, r, &s) {
+				return
+			}
+			s.Options.Auth = s.Options.Auth.restoreSecrets(stored.Options.Auth)
+			if _, err := updateSettings(s); err != nil {
+				writeAPIError(w, http.StatusBadRequest, apiErrInvalidRequest, err.Error())
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, currentSettings().redacted())
+	})
+
+	mux.HandleFunc("/api/v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+
+	// 其余路径统一返回JSON格式的404
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, apiErrNotFound, fmt.Sprintf("接口 %s 不存在", r.URL.Path))
+	})
+
+	return mux
+}
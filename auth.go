@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName = "webcut_session"
+	sessionTTL        = 7 * 24 * time.Hour
+	csrfHeaderName    = "X-CSRF-Token"
+)
+
+// csrfKey 本进程生成CSRF令牌的密钥
+var csrfKey = randomToken()
+
+// csrfToken 返回请求所属会话的CSRF令牌（会话ID的HMAC），嵌入界面页面，界面发出的POST/PUT等请求必须在请求头中带上
+// 每个会话的令牌不同，不能用于其他用户的会话；未启用认证时没有会话，使用同一个令牌
+func csrfToken(r *http.Request) string {
+	sessionID := ""
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		sessionID = cookie.Value
+	}
+	mac := hmac.New(sha256.New, []byte(csrfKey))
+	mac.Write([]byte(sessionID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomToken 生成随机令牌
+func randomToken() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// loadAPIKeys 读取API密钥文件，每行一个“用户名:密钥”，#开头为注释
+func loadAPIKeys(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keys := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		user, key, ok := strings.Cut(text, ":")
+		user, key = strings.TrimSpace(user), strings.TrimSpace(key)
+		if !ok || user == "" || key == "" {
+			return nil, fmt.Errorf("%s 第 %d 行格式错误，应为 用户名:密钥", path, line)
+		}
+		keys[key] = user
+	}
+	return keys, scanner.Err()
+}
+
+type userContextKey struct{}
+
+// requestUser 返回认证后的用户名，未启用认证时为空
+func requestUser(r *http.Request) string {
+	user, _ := r.Context().Value(userContextKey{}).(string)
+	return user
+}
+
+type session struct {
+	user    string
+	expires time.Time
+}
+
+// guard 服务器的访问控制：Host/Origin检查、令牌或API密钥认证、CSRF保护
+type guard struct {
+	cfg ServerConfig
+
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+func newGuard(cfg ServerConfig) *guard {
+	return &guard{cfg: cfg, sessions: make(map[string]session)}
+}
+
+// authEnabled 是否配置了令牌或API密钥
+func (g *guard) authEnabled() bool {
+	return g.cfg.Token != "" || len(g.cfg.APIKeys) > 0
+}
+
+// lookupKey 按令牌或API密钥查找用户，比较时间恒定
+func (g *guard) lookupKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	if g.cfg.Token != "" && subtle.ConstantTimeCompare([]byte(key), []byte(g.cfg.Token)) == 1 {
+		return "token", true
+	}
+	for k, user := range g.cfg.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			return user, true
+		}
+	}
+	return "", false
+}
+
+// sessionUser 按会话Cookie查找用户
+func (g *guard) sessionUser(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return "", false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.sessions[cookie.Value]
+	if !ok || time.Now().After(s.expires) {
+		delete(g.sessions, cookie.Value)
+		return "", false
+	}
+	return s.user, true
+}
+
+// startSession 创建会话并设置Cookie
+func (g *guard) startSession(w http.ResponseWriter, user string) {
+	id := randomToken()
+	g.mu.Lock()
+	g.sessions[id] = session{user: user, expires: time.Now().Add(sessionTTL)}
+	g.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(sessionTTL / time.Second),
+		HttpOnly: true,
+		Secure:   g.cfg.tls(),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// hostAllowed 未启用认证时只接受本机Host，防止DNS重绑定让外部网页访问本地服务
+func (g *guard) hostAllowed(r *http.Request) bool {
+	if g.authEnabled() {
+		return true
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// originAllowed 检查浏览器请求的来源：同源或在允许列表中；没有Origin时按 Sec-Fetch-Site 拒绝跨站请求，
+// 但允许从其他网站点击链接打开页面
+func (g *guard) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		if r.Header.Get("Sec-Fetch-Site") != "cross-site" {
+			return true
+		}
+		return r.Method == "GET" && r.Header.Get("Sec-Fetch-Mode") == "navigate"
+	}
+	if g.corsAllowed(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// corsAllowed 判断来源是否在跨域允许列表中
+func (g *guard) corsAllowed(origin string) bool {
+	for _, allowed := range g.cfg.AllowedOrigins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeMethod 不修改状态的请求方法不需要CSRF令牌
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// reject 拒绝请求，/api/v1 返回统一的JSON错误
+func reject(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	fmt.Printf("拒绝请求 %s %s（来自 %s）: %s\n", r.Method, r.URL.Path, r.RemoteAddr, message)
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		writeAPIError(w, status, code, message)
+		return
+	}
+	http.Error(w, message, status)
+}
+
+// wrap 在处理请求前依次进行Host、Origin、认证和CSRF检查
+func (g *guard) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.hostAllowed(r) {
+			reject(w, r, http.StatusForbidden, apiErrForbidden, "不允许的Host")
+			return
+		}
+		if !g.originAllowed(r) {
+			reject(w, r, http.StatusForbidden, apiErrForbidden, "不允许的跨站请求")
+			return
+		}
+
+		// 允许列表中的来源可以跨域调用，需使用令牌认证
+		if origin := r.Header.Get("Origin"); origin != "" && g.corsAllowed(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Last-Event-ID")
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		if r.URL.Path == "/login" {
+			g.handleLogin(w, r)
+			return
+		}
+
+		// 令牌或API密钥认证（Authorization 头或WebSocket子协议）的请求不依赖Cookie，不需要CSRF令牌
+		bearer := false
+		user := ""
+		if g.authEnabled() {
+			var ok bool
+			key, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found {
+				key, found = wsBearerToken(r)
+			}
+			if found {
+				if user, ok = g.lookupKey(strings.TrimSpace(key)); !ok {
+					reject(w, r, http.StatusUnauthorized, apiErrUnauthorized, "令牌无效")
+					return
+				}
+				bearer = true
+			} else if user, ok = g.sessionUser(r); !ok {
+				if r.Method == "GET" && r.URL.Path == "/" {
+					http.Redirect(w, r, "/login", http.StatusSeeOther)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="webcut"`)
+				reject(w, r, http.StatusUnauthorized, apiErrUnauthorized, "需要认证")
+				return
+			}
+		}
+
+		if !bearer && !isSafeMethod(r.Method) &&
+			!hmac.Equal([]byte(r.Header.Get(csrfHeaderName)), []byte(csrfToken(r))) {
+			reject(w, r, http.StatusForbidden, apiErrForbidden, "CSRF令牌无效")
+			return
+		}
+
+		if user != "" {
+			r = r.WithContext(context.WithValue(r.Context(), userContextKey{}, user))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+	<meta charset="UTF-8">
+	<title>WebCut 登录</title>
+	<style>
+		body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', 'Roboto', sans-serif; padding: 60px; }
+		form { max-width: 360px; margin: 0 auto; }
+		input { width: 100%; padding: 8px; margin: 8px 0; box-sizing: border-box; }
+		button { padding: 10px 20px; background-color: #3498db; color: white; border: none; border-radius: 4px; cursor: pointer; }
+		.error { color: #721c24; }
+	</style>
+</head>
+<body>
+	<form method="POST" action="/login">
+		<h2>WebCut 登录</h2>
+		{{if .}}<p class="error">{{.}}</p>{{end}}
+		<input type="password" name="token" placeholder="访问令牌或API密钥" autofocus>
+		<button type="submit">登录</button>
+	</form>
+</body>
+</html>
+`))
+
+// handleLogin 界面登录：输入令牌或API密钥后建立会话
+func (g *guard) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !g.authEnabled() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	message := ""
+	if r.Method == "POST" {
+		if user, ok := g.lookupKey(r.PostFormValue("token")); ok {
+			g.startSession(w, user)
+			fmt.Printf("用户 %s 从 %s 登录\n", user, r.RemoteAddr)
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		fmt.Printf("来自 %s 的登录失败\n", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		message = "令牌无效"
+	}
+	loginTemplate.Execute(w, message)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFTokenPerSession(t *testing.T) {
+	g := newGuard(ServerConfig{APIKeys: map[string]string{"key-alice": "alice", "key-bob": "bob"}})
+	handler := g.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(csrfToken(r)))
+	}))
+
+	// login 建立会话并返回会话Cookie和界面页面中的CSRF令牌
+	login := func(user string) (*http.Cookie, string) {
+		rec := httptest.NewRecorder()
+		g.startSession(rec, user)
+		cookie := rec.Result().Cookies()[0]
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		page := httptest.NewRecorder()
+		handler.ServeHTTP(page, req)
+		return cookie, page.Body.String()
+	}
+	aliceCookie, aliceToken := login("alice")
+	bobCookie, bobToken := login("bob")
+	if aliceToken == bobToken {
+		t.Fatal("不同会话的CSRF令牌相同")
+	}
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		token  string
+		want   int
+	}{
+		{"本会话令牌", aliceCookie, aliceToken, http.StatusOK},
+		{"其他会话令牌", aliceCookie, bobToken, http.StatusForbidden},
+		{"缺少令牌", bobCookie, "", http.StatusForbidden},
+		{"另一会话本身的令牌", bobCookie, bobToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/targets", nil)
+		req.AddCookie(tt.cookie)
+		req.Header.Set(csrfHeaderName, tt.token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: 状态码 = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
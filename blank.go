@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"time"
+)
+
+const (
+	// 可见文本少于该长度才可能判定为空白页，避免纯色背景但有内容的页面被误判
+	blankMaxTextSize = 20
+	// 亮度标准差低于该值视为画面几乎没有变化（0-255）
+	blankMaxStdDev = 3.0
+	// 量化后颜色数不超过该值视为纯色画面
+	blankMaxColors = 1
+	// 空白页重试时每次额外增加的等待时间
+	blankRetryWait = 5 * time.Second
+	// 分析时每个方向最多抽样的像素数
+	blankSampleSize = 200
+)
+
+// detectBlank 根据截图像素和可见文本长度判断是否为空白或接近空白的页面
+// 返回是否空白及判断依据，图片无法解码时不判定为空白
+func detectBlank(data []byte, textSize int) (bool, string) {
+	if textSize > blankMaxTextSize {
+		return false, ""
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return false, ""
+	}
+	stdDev, colors := imageStats(img)
+	if stdDev > blankMaxStdDev && colors > blankMaxColors {
+		return false, ""
+	}
+	return true, fmt.Sprintf("亮度标准差 %.1f，颜色数 %d，可见文本 %d 字符", stdDev, colors, textSize)
+}
+
+// imageStats 抽样计算图片的亮度标准差和量化颜色数（每通道保留高4位）
+func imageStats(img image.Image) (float64, int) {
+	bounds := img.Bounds()
+	stepX := max(bounds.Dx()/blankSampleSize, 1)
+	stepY := max(bounds.Dy()/blankSampleSize, 1)
+	colors := make(map[uint32]struct{})
+	var sum, sumSq float64
+	var count int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			r, g, b = r>>8, g>>8, b>>8
+			lum := 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			sum += lum
+			sumSq += lum * lum
+			count++
+			colors[(r>>4)<<8|(g>>4)<<4|b>>4] = struct{}{}
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	mean := sum / float64(count)
+	variance := math.Max(sumSq/float64(count)-mean*mean, 0)
+	return math.Sqrt(variance), len(colors)
+}
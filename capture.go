@@ -0,0 +1,586 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// ClipRect 截图裁剪区域（CSS像素，相对于页面左上角）
+type ClipRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// CaptureRule 按URL匹配的截图规则，命中后覆盖任务级的元素/裁剪设置
+type CaptureRule struct {
+	Match        string    `json:"match"`        // URL中包含该字符串即命中（不区分大小写）
+	Selector     string    `json:"selector"`     // 元素选择器
+	SelectorType string    `json:"selectorType"` // css 或 xpath，默认css
+	Clip         *ClipRect `json:"clip"`         // 显式裁剪区域
+}
+
+// Viewport 浏览器视口大小（CSS像素），为空时使用 1920x1080
+type Viewport struct {
+	Width  int64 `json:"width"`
+	Height int64 `json:"height"`
+}
+
+// 视口大小的默认值和上限
+const (
+	defaultViewportWidth  = 1920
+	defaultViewportHeight = 1080
+	maxViewportSize       = 8192
+)
+
+// size 返回实际使用的视口大小，未设置或超出范围的边使用默认值
+func (v *Viewport) size() (int64, int64) {
+	width, height := int64(defaultViewportWidth), int64(defaultViewportHeight)
+	if v != nil && v.Width > 0 && v.Width <= maxViewportSize {
+		width = v.Width
+	}
+	if v != nil && v.Height > 0 && v.Height <= maxViewportSize {
+		height = v.Height
+	}
+	return width, height
+}
+
+// WaitOptions 页面跳转稳定后、截图前的额外等待
+type WaitOptions struct {
+	Selector string `json:"selector,omitempty"` // 等待该CSS选择器的元素可见
+	Delay    int    `json:"delay,omitempty"`    // 再等待的毫秒数，不超过 maxWaitDelay
+}
+
+// maxWaitDelay 额外等待时间的上限
+const maxWaitDelay = 30 * time.Second
+
+// delay 返回额外等待时间
+func (w WaitOptions) delay() time.Duration {
+	return min(max(time.Duration(w.Delay)*time.Millisecond, 0), maxWaitDelay)
+}
+
+// CaptureAuth 访问需要认证的页面：HTTP Basic认证只在导航目标时发送，Cookie按目标URL设置，
+// 自定义请求头只随发往目标主机的请求发送。带认证信息的截图使用独立的浏览器上下文，结束后销毁
+type CaptureAuth struct {
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Cookie   string            `json:"cookie,omitempty"`  // 如 "session=abc; lang=zh"
+	Headers  map[string]string `json:"headers,omitempty"` // 如 {"Authorization": "Bearer ..."}
+}
+
+// redactedValue 任务状态等接口返回选项时替换认证信息
+const redactedValue = "******"
+
+// redacted 返回隐藏密码、Cookie和请求头值的副本，用于对外返回任务选项
+func (a *CaptureAuth) redacted() *CaptureAuth {
+	if a == nil {
+		return nil
+	}
+	copied := &CaptureAuth{Username: a.Username}
+	if a.Password != "" {
+		copied.Password = redactedValue
+	}
+	if a.Cookie != "" {
+		copied.Cookie = redactedValue
+	}
+	for name := range a.Headers {
+		if copied.Headers == nil {
+			copied.Headers = make(map[string]string)
+		}
+		copied.Headers[name] = redactedValue
+	}
+	return copied
+}
+
+// restoreSecrets 返回提交的认证信息副本，仍为 redactedValue 占位符的字段取已保存的值，
+// 客户端读取设置后原样提交时不会用占位符覆盖真实的密码、Cookie和请求头
+func (a *CaptureAuth) restoreSecrets(stored *CaptureAuth) *CaptureAuth {
+	if a == nil {
+		return nil
+	}
+	if stored == nil {
+		stored = &CaptureAuth{}
+	}
+	restore := func(value, saved string) string {
+		if value == redactedValue {
+			return saved
+		}
+		return value
+	}
+	copied := &CaptureAuth{
+		Username: a.Username,
+		Password: restore(a.Password, stored.Password),
+		Cookie:   restore(a.Cookie, stored.Cookie),
+	}
+	for name, value := range a.Headers {
+		if copied.Headers == nil {
+			copied.Headers = make(map[string]string)
+		}
+		copied.Headers[name] = restore(value, stored.Headers[name])
+	}
+	return copied
+}
+
+// navigateURL 返回导航地址，设置了用户名时附带 Basic 认证信息
+func (a *CaptureAuth) navigateURL(rawURL string) string {
+	if a == nil || a.Username == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.User = url.UserPassword(a.Username, a.Password)
+	return u.String()
+}
+
+// isolated 是否需要独立的浏览器上下文：Cookie、请求头和Basic认证缓存都不能留在池中的标签页里
+func (a *CaptureAuth) isolated() bool {
+	return a != nil && (a.Username != "" || a.Cookie != "" || len(a.Headers) > 0)
+}
+
+// authContext 带认证信息时在池中的浏览器里新建独立的浏览器上下文（相当于隐身窗口），
+// 返回的关闭函数关闭标签页并销毁其中的Cookie和认证缓存；不带认证信息时直接使用池中的标签页
+func (a *CaptureAuth) authContext(baseCtx context.Context) (context.Context, context.CancelFunc, error) {
+	if !a.isolated() {
+		return baseCtx, func() {}, nil
+	}
+	// 新建浏览器上下文前浏览器需已启动
+	if err := chromedp.Run(baseCtx); err != nil {
+		return nil, nil, fmt.Errorf("启动浏览器失败: %v", err)
+	}
+	ctx, cancel := chromedp.NewContext(baseCtx, chromedp.WithNewBrowserContext())
+	return ctx, cancel, nil
+}
+
+// apply 在导航前设置Cookie，并拦截请求只为发往目标主机的请求附加自定义请求头，
+// 页面引用的第三方资源不会收到这些请求头
+func (a *CaptureAuth) apply(ctx context.Context, rawURL string) error {
+	if a == nil || (a.Cookie == "" && len(a.Headers) == 0) {
+		return nil
+	}
+	if err := network.Enable().Do(ctx); err != nil {
+		return err
+	}
+	for _, pair := range strings.Split(a.Cookie, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" {
+			continue
+		}
+		if err := network.SetCookie(name, value).WithURL(rawURL).Do(ctx); err != nil {
+			return fmt.Errorf("设置Cookie %s 失败: %v", name, err)
+		}
+	}
+	if len(a.Headers) == 0 {
+		return nil
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("解析目标URL失败: %v", err)
+	}
+	host := strings.ToLower(target.Host)
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		// 监听回调中不能阻塞，放行请求在单独的goroutine中执行
+		go func() {
+			continued := fetch.ContinueRequest(paused.RequestID)
+			if u, err := url.Parse(paused.Request.URL); err == nil && strings.ToLower(u.Host) == host {
+				continued = continued.WithHeaders(a.requestHeaders(paused.Request.Headers))
+			}
+			if err := continued.Do(ctx); err != nil && ctx.Err() == nil {
+				fmt.Printf("放行请求 %s 失败: %v\n", paused.Request.URL, err)
+			}
+		}()
+	})
+	if err := fetch.Enable().Do(ctx); err != nil {
+		return fmt.Errorf("设置请求头失败: %v", err)
+	}
+	return nil
+}
+
+// applyToRequest 为发往目标主机的HTTP请求（如获取favicon）附加Basic认证、Cookie和自定义请求头
+func (a *CaptureAuth) applyToRequest(req *http.Request, targetURL string) {
+	if a == nil || !sameHost(req.URL, targetURL) {
+		return
+	}
+	if a.Username != "" {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+	if a.Cookie != "" {
+		req.Header.Set("Cookie", a.Cookie)
+	}
+	for name, value := range a.Headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// stripFromRequest 请求不是发往目标主机时（如跳转后）去掉认证信息
+func (a *CaptureAuth) stripFromRequest(req *http.Request, targetURL string) {
+	if a == nil || sameHost(req.URL, targetURL) {
+		return
+	}
+	req.Header.Del("Authorization")
+	req.Header.Del("Cookie")
+	for name := range a.Headers {
+		req.Header.Del(name)
+	}
+}
+
+// sameHost 判断请求地址与目标URL的主机（含端口）是否相同
+func sameHost(u *url.URL, targetURL string) bool {
+	target, err := url.Parse(targetURL)
+	return err == nil && strings.EqualFold(u.Host, target.Host)
+}
+
+// requestHeaders 在请求原有的请求头上附加自定义请求头，同名（不区分大小写）时以自定义的为准
+func (a *CaptureAuth) requestHeaders(original network.Headers) []*fetch.HeaderEntry {
+	entries := make([]*fetch.HeaderEntry, 0, len(original)+len(a.Headers))
+	for name, value := range original {
+		overridden := false
+		for custom := range a.Headers {
+			if strings.EqualFold(name, custom) {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			entries = append(entries, &fetch.HeaderEntry{Name: name, Value: fmt.Sprint(value)})
+		}
+	}
+	for name, value := range a.Headers {
+		entries = append(entries, &fetch.HeaderEntry{Name: name, Value: value})
+	}
+	return entries
+}
+
+// CaptureOptions 单次截图的选项
+type CaptureOptions struct {
+	FullPage     bool          `json:"fullPage"`
+	Selector     string        `json:"selector"`
+	SelectorType string        `json:"selectorType"`
+	Clip         *ClipRect     `json:"clip"`
+	Rules        []CaptureRule `json:"rules"`
+	SavePDF      bool          `json:"savePdf"`   // 额外保存打印PDF
+	SaveMHTML    bool          `json:"saveMhtml"` // 额外保存MHTML完整快照
+
+	SaveHAR        bool  `json:"saveHar"`        // 记录网络请求并保存HAR文件
+	HARBodies      bool  `json:"harBodies"`      // HAR中包含响应体
+	HARMaxBodySize int64 `json:"harMaxBodySize"` // 单个响应体大小上限（字节），默认1MB
+
+	Scope        []string `json:"scope"`        // 任务范围（域名、IP、CIDR），为空时取目标列表的上级域名
+	DiscoverSANs bool     `json:"discoverSans"` // 将证书SAN中范围内的主机加入截图队列
+
+	RetryBlank bool        `json:"retryBlank"` // 截图为空白页时延长等待重新截图
+	Retry      RetryPolicy `json:"retry"`      // 失败重试策略
+
+	Viewport *Viewport    `json:"viewport,omitempty"` // 视口大小
+	Wait     WaitOptions  `json:"wait"`               // 截图前的额外等待
+	Auth     *CaptureAuth `json:"auth,omitempty"`     // 认证信息
+
+	// 重试前的等待方式，批量任务借此在等待期间让出并发名额；为空时直接休眠
+	sleep func(time.Duration)
+	// 批量任务中目标的上下文，取消或跳过目标时以带类型的 CaptureError 为原因取消；为空时不可取消
+	ctx context.Context
+	// 批量任务实际生效的范围（包括未指定时的默认范围），获取favicon等附加请求只发往范围内的主机
+	scope *Scope
+}
+
+// wait 按配置的方式等待重试
+func (o CaptureOptions) wait(d time.Duration) {
+	if o.sleep != nil {
+		o.sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// context 返回目标的上下文
+func (o CaptureOptions) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// canceled 目标被取消或跳过时返回取消原因
+func (o CaptureOptions) canceled() error {
+	if o.ctx == nil || o.ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(o.ctx)
+}
+
+// 截图模式
+const (
+	captureModeViewport = "viewport"
+	captureModeFullPage = "fullPage"
+	captureModeElement  = "element"
+	captureModeClip     = "clip"
+)
+
+// captureModeLabels 截图模式的显示名称，用于日志
+var captureModeLabels = map[string]string{
+	captureModeViewport: "视口",
+	captureModeFullPage: "整页",
+	captureModeElement:  "元素",
+	captureModeClip:     "区域",
+}
+
+// CaptureResult 单个URL的截图结果
+type CaptureResult struct {
+	ID       string `json:"id,omitempty"`    // 结果ID，运行编号加序号
+	ImageURL string `json:"image,omitempty"` // 截图原图地址
+	ThumbURL string `json:"thumb,omitempty"` // 缩略图地址
+	URL      string `json:"url"`
+	FinalURL string `json:"finalUrl,omitempty"`
+	Mode     string `json:"mode"`               // 实际使用的截图模式
+	Fallback string `json:"fallback,omitempty"` // 元素/裁剪截图回退到其他模式时的原因
+	Title    string `json:"title,omitempty"`    // 页面标题 document.title
+	TextSize int    `json:"textSize"`           // 可见文本长度（字符数）
+
+	// 主文档的HTTP状态码和响应头（头部名称为小写），以及页面的Cookie名、meta标签和脚本地址
+	StatusCode int64             `json:"statusCode,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Cookies    []string          `json:"cookies,omitempty"`
+	Meta       map[string]string `json:"meta,omitempty"`
+	Scripts    []string          `json:"scripts,omitempty"`
+
+	// 指纹识别出的产品及版本
+	Technologies []Technology `json:"technologies,omitempty"`
+
+	// favicon地址及Shodan风格mmh3哈希、MD5，用于按图标归类和在Shodan/FOFA中检索
+	FaviconURL  string `json:"faviconUrl,omitempty"`
+	FaviconMMH3 string `json:"faviconMmh3,omitempty"`
+	FaviconMD5  string `json:"faviconMd5,omitempty"`
+	FaviconType string `json:"-"`
+	Favicon     []byte `json:"-"`
+
+	// 页面分类（登录页、默认页面、错误页面、WAF拦截等）及显示名称
+	Category      string `json:"category,omitempty"`
+	CategoryLabel string `json:"categoryLabel,omitempty"`
+
+	// 截图是否为空白或接近空白页面，以及判断依据
+	Blank       bool   `json:"blank,omitempty"`
+	BlankReason string `json:"blankReason,omitempty"`
+
+	// 截图的感知哈希（dHash），用于把外观相似的页面归为一组
+	PerceptualHash string `json:"phash,omitempty"`
+
+	// 失败原因及错误类型；截图成功但状态码为4xx/5xx时只设置 ErrorKind 为 http-status
+	Error     string    `json:"error,omitempty"`
+	ErrorKind ErrorKind `json:"errorKind,omitempty"`
+	Attempts  int       `json:"attempts,omitempty"` // 实际尝试次数
+	// 最后一次尝试的开始时间
+	CapturedAt time.Time `json:"capturedAt"`
+
+	Image []byte `json:"-"`
+	PDF   []byte `json:"-"`
+	MHTML []byte `json:"-"`
+	HAR   []byte `json:"-"`
+	DOM   []byte `json:"-"` // 最终渲染的DOM document.documentElement.outerHTML
+	Text  []byte `json:"-"` // 可见文本 document.body.innerText
+
+	// 页面控制台输出、未捕获的JS异常和加载失败的请求
+	Console        []ConsoleMessage `json:"console,omitempty"`
+	Exceptions     []PageException  `json:"exceptions,omitempty"`
+	FailedRequests []FailedRequest  `json:"failedRequests,omitempty"`
+
+	// HTTPS目标的叶子证书，以及证书SAN中发现并加入队列的新目标
+	Certificate *CertificateInfo `json:"certificate,omitempty"`
+	CertError   string           `json:"certError,omitempty"`
+	Discovered  []string         `json:"discovered,omitempty"`
+
+	// Artifacts 已保存到运行目录的附件，键为附件类型，值为相对文件名
+	Artifacts map[string]string `json:"artifacts,omitempty"`
+
+	// 审阅记录单独保存在运行目录的 triage.json 中，接口返回和导出时附带
+	Triage *Triage `json:"triage,omitempty"`
+}
+
+// resolveFor 根据URL匹配规则，返回该URL实际生效的选项
+func (o CaptureOptions) resolveFor(url string) CaptureOptions {
+	resolved := o
+	resolved.Rules = nil
+	lowerURL := strings.ToLower(url)
+	for _, rule := range o.Rules {
+		if rule.Match == "" || !strings.Contains(lowerURL, strings.ToLower(rule.Match)) {
+			continue
+		}
+		// 第一条命中的规则生效
+		resolved.Selector = rule.Selector
+		resolved.SelectorType = rule.SelectorType
+		resolved.Clip = rule.Clip
+		break
+	}
+	return resolved
+}
+
+// selectorQueryOption 将选择器类型转换为chromedp查询选项
+func selectorQueryOption(selectorType string) chromedp.QueryOption {
+	if strings.EqualFold(selectorType, "xpath") {
+		return chromedp.BySearch
+	}
+	return chromedp.ByQuery
+}
+
+// takeScreenshot 按选项截图：依次尝试元素、裁剪区域，都不可用时按选项截取整页或视口，
+// 回退的原因依次记录在结果中
+func takeScreenshot(ctx context.Context, opts CaptureOptions, buf *[]byte, result *CaptureResult) error {
+	var reasons []string
+	defer func() {
+		result.Fallback = strings.Join(reasons, "；")
+	}()
+
+	if opts.Selector != "" {
+		// 元素查找单独限时，避免选择器不存在时耗尽整个截图超时
+		var nodes []*cdp.Node
+		lookupCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		err := chromedp.Nodes(opts.Selector, &nodes, selectorQueryOption(opts.SelectorType), chromedp.NodeVisible).Do(lookupCtx)
+		cancel()
+		if err == nil && len(nodes) > 0 {
+			err = chromedp.ScreenshotNodes(nodes[:1], 1, buf).Do(ctx)
+			if err == nil {
+				result.Mode = captureModeElement
+				return nil
+			}
+			reasons = append(reasons, fmt.Sprintf("元素截图失败: %v", err))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("未找到元素: %s", opts.Selector))
+		}
+	}
+	if opts.Clip != nil {
+		if opts.Clip.Width > 0 && opts.Clip.Height > 0 {
+			data, err := page.CaptureScreenshot().
+				WithFormat(page.CaptureScreenshotFormatPng).
+				WithCaptureBeyondViewport(true).
+				WithClip(&page.Viewport{
+					X:      opts.Clip.X,
+					Y:      opts.Clip.Y,
+					Width:  opts.Clip.Width,
+					Height: opts.Clip.Height,
+					Scale:  1,
+				}).Do(ctx)
+			if err == nil {
+				*buf = data
+				result.Mode = captureModeClip
+				return nil
+			}
+			reasons = append(reasons, fmt.Sprintf("区域截图失败: %v", err))
+		} else {
+			reasons = append(reasons, "裁剪区域无效")
+		}
+	}
+
+	if opts.FullPage {
+		result.Mode = captureModeFullPage
+		return chromedp.FullScreenshot(buf, 90).Do(ctx) // 提高质量
+	}
+	result.Mode = captureModeViewport
+	return chromedp.CaptureScreenshot(buf).Do(ctx)
+}
+
+// captureArtifacts 在同一浏览器会话中生成PDF和MHTML附件，失败只记录日志不影响截图结果
+func captureArtifacts(ctx context.Context, opts CaptureOptions, result *CaptureResult) error {
+	if opts.SavePDF {
+		data, _, err := page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+		if err != nil {
+			fmt.Printf("URL %s 生成PDF失败: %v\n", result.URL, err)
+		} else {
+			result.PDF = data
+		}
+	}
+	if opts.SaveMHTML {
+		data, err := page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+		if err != nil {
+			fmt.Printf("URL %s 生成MHTML失败: %v\n", result.URL, err)
+		} else {
+			result.MHTML = []byte(data)
+		}
+	}
+	return nil
+}
+
+// pageContent 页面渲染后的DOM、可见文本、标题、meta标签和脚本地址
+type pageContent struct {
+	HTML    string            `json:"html"`
+	Text    string            `json:"text"`
+	Title   string            `json:"title"`
+	Meta    map[string]string `json:"meta"`
+	Scripts []string          `json:"scripts"`
+	Icon    string            `json:"icon"`
+}
+
+// capturePageContent 读取最终渲染的DOM、可见文本和标题，便于检索和后续分析
+func capturePageContent(ctx context.Context, result *CaptureResult) error {
+	var content pageContent
+	err := chromedp.Evaluate(`({
+		html: document.documentElement ? document.documentElement.outerHTML : '',
+		text: document.body ? document.body.innerText : '',
+		title: document.title || '',
+		meta: Array.from(document.querySelectorAll('meta[name], meta[property]')).reduce(function(m, el) {
+			m[(el.getAttribute('name') || el.getAttribute('property')).toLowerCase()] = el.getAttribute('content') || '';
+			return m;
+		}, {}),
+		scripts: Array.from(document.scripts).map(function(s) { return s.src; }).filter(function(src) { return src; }),
+		icon: (document.querySelector('link[rel~="icon" i]') || {}).href || ''
+	})`, &content).Do(ctx)
+	if err != nil {
+		fmt.Printf("URL %s 读取页面内容失败: %v\n", result.URL, err)
+		return nil
+	}
+	result.Title = strings.TrimSpace(content.Title)
+	result.DOM = []byte(content.HTML)
+	result.Text = []byte(content.Text)
+	result.TextSize = len([]rune(strings.TrimSpace(content.Text)))
+	result.Meta = content.Meta
+	result.Scripts = content.Scripts
+	result.FaviconURL = content.Icon
+
+	// 包括HttpOnly在内的Cookie名，用于指纹识别
+	if cookies, err := network.GetCookies().Do(ctx); err == nil {
+		for _, cookie := range cookies {
+			result.Cookies = append(result.Cookies, cookie.Name)
+		}
+	}
+	return nil
+}
+
+// certificateFetch 与截图并行进行的证书获取
+type certificateFetch struct {
+	done chan struct{}
+	info *CertificateInfo
+	err  error
+}
+
+// startCertificateFetch 对HTTPS目标启动独立的TLS握手，非HTTPS目标立即完成
+func startCertificateFetch(url string) *certificateFetch {
+	fetch := &certificateFetch{done: make(chan struct{})}
+	go func() {
+		defer close(fetch.done)
+		fetch.info, fetch.err = fetchCertificate(url)
+	}()
+	return fetch
+}
+
+// apply 等待证书获取完成并写入结果
+func (f *certificateFetch) apply(result *CaptureResult) {
+	<-f.done
+	result.Certificate = f.info
+	if f.err != nil {
+		result.CertError = f.err.Error()
+	}
+}
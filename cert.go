@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 证书握手超时时间
+const certDialTimeout = 8 * time.Second
+
+// CertificateInfo 目标HTTPS服务的叶子证书信息
+type CertificateInfo struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SANs              []string  `json:"sans,omitempty"`
+	SerialNumber      string    `json:"serialNumber"`
+	NotBefore         time.Time `json:"notBefore"`
+	NotAfter          time.Time `json:"notAfter"`
+	DaysLeft          int       `json:"daysLeft"`
+	FingerprintSHA256 string    `json:"fingerprintSha256"`
+	FingerprintSHA1   string    `json:"fingerprintSha1"`
+	SignatureAlgo     string    `json:"signatureAlgorithm"`
+	TLSVersion        string    `json:"tlsVersion"`
+
+	SelfSigned       bool   `json:"selfSigned"`
+	Expired          bool   `json:"expired"`
+	NotYetValid      bool   `json:"notYetValid"`
+	HostnameMismatch bool   `json:"hostnameMismatch"`
+	Untrusted        bool   `json:"untrusted"`
+	VerifyError      string `json:"verifyError,omitempty"`
+}
+
+// Anomalies 返回证书异常的中文描述，用于报告和画廊标记
+func (c *CertificateInfo) Anomalies() []string {
+	var anomalies []string
+	if c.Expired {
+		anomalies = append(anomalies, "证书已过期")
+	}
+	if c.NotYetValid {
+		anomalies = append(anomalies, "证书尚未生效")
+	}
+	if c.SelfSigned {
+		anomalies = append(anomalies, "自签名证书")
+	} else if c.Untrusted {
+		anomalies = append(anomalies, "证书链不受信任")
+	}
+	if c.HostnameMismatch {
+		anomalies = append(anomalies, "证书域名不匹配")
+	}
+	return anomalies
+}
+
+// fetchCertificate 通过独立的TLS握手获取目标的叶子证书（浏览器忽略了证书错误，这里单独记录）
+func fetchCertificate(rawURL string) (*CertificateInfo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return nil, nil
+	}
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	config := &tls.Config{InsecureSkipVerify: true}
+	if net.ParseIP(host) == nil {
+		config.ServerName = host
+	}
+	dialer := &net.Dialer{Timeout: certDialTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), config)
+	if err != nil {
+		return nil, fmt.Errorf("TLS握手失败: %v", err)
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("服务器未返回证书")
+	}
+	info := describeCertificate(state.PeerCertificates[0], time.Now())
+	info.TLSVersion = tls.VersionName(state.Version)
+
+	// 使用系统根证书校验证书链和域名
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, verifyErr := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: intermediates,
+	})
+	if verifyErr != nil {
+		info.VerifyError = verifyErr.Error()
+		if _, ok := verifyErr.(x509.HostnameError); ok {
+			info.HostnameMismatch = true
+		} else {
+			info.Untrusted = true
+		}
+	}
+	if state.PeerCertificates[0].VerifyHostname(host) != nil {
+		info.HostnameMismatch = true
+	}
+	return info, nil
+}
+
+// describeCertificate 提取证书字段并判断自签名、过期等状态
+func describeCertificate(cert *x509.Certificate, now time.Time) *CertificateInfo {
+	sha256Sum := sha256.Sum256(cert.Raw)
+	sha1Sum := sha1.Sum(cert.Raw)
+
+	info := &CertificateInfo{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      cert.SerialNumber.Text(16),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		DaysLeft:          int(cert.NotAfter.Sub(now).Hours() / 24),
+		FingerprintSHA256: hex.EncodeToString(sha256Sum[:]),
+		FingerprintSHA1:   hex.EncodeToString(sha1Sum[:]),
+		SignatureAlgo:     cert.SignatureAlgorithm.String(),
+		Expired:           now.After(cert.NotAfter),
+		NotYetValid:       now.Before(cert.NotBefore),
+	}
+	info.SANs = append(info.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	// 颁发者与主体相同且能用自身公钥验证签名，即为自签名
+	// 不用CheckSignatureFrom，设备自签证书常缺少CA标记
+	if bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil {
+		info.SelfSigned = true
+	}
+	return info
+}
+
+// sanTargets 根据证书SAN生成新的候选目标URL，跳过通配符和原目标自身
+func sanTargets(rawURL string, cert *CertificateInfo) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil || cert == nil {
+		return nil
+	}
+	var targets []string
+	seen := map[string]bool{strings.ToLower(u.Hostname()): true}
+	for _, san := range cert.SANs {
+		host := strings.ToLower(strings.TrimSuffix(san, "."))
+		if host == "" || strings.Contains(host, "*") || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hostPort := host
+		if strings.Contains(host, ":") {
+			hostPort = "[" + host + "]" // IPv6
+		}
+		if u.Port() != "" {
+			hostPort = net.JoinHostPort(host, u.Port())
+		}
+		targets = append(targets, "https://"+hostPort)
+	}
+	return targets
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// 外部页面分类规则文件，存在时替换内置规则
+var classificationFile = "classifications.json"
+
+//go:embed classifications.json
+var defaultClassifications []byte
+
+// ClassificationRule 页面分类规则，按文件中的顺序依次匹配，第一条命中的规则决定分类
+// 模式与指纹规则相同，为不区分大小写的正则表达式；任一模式命中且所有限制条件满足即命中，
+// 规则没有任何模式时只检查限制条件
+type ClassificationRule struct {
+	Category string            `json:"category"` // 分类标识，如 login、waf
+	Label    string            `json:"label"`    // 界面上显示的名称
+	Title    []string          `json:"title"`    // 页面标题模式
+	HTML     []string          `json:"html"`     // 渲染后DOM模式
+	Text     []string          `json:"text"`     // 可见文本模式
+	URL      []string          `json:"url"`      // 最终URL模式
+	Headers  map[string]string `json:"headers"`  // 响应头名称 -> 值模式
+
+	// 限制条件
+	Blank       bool     `json:"blank"`       // 要求截图被判定为空白
+	StatusMin   int64    `json:"statusMin"`   // 状态码下限（含）
+	StatusMax   int64    `json:"statusMax"`   // 状态码上限（含）
+	MaxTextSize *int     `json:"maxTextSize"` // 可见文本长度上限
+	NotHTML     []string `json:"notHtml"`     // DOM中不能出现的模式
+
+	title   []*fingerprintPattern
+	html    []*fingerprintPattern
+	text    []*fingerprintPattern
+	url     []*fingerprintPattern
+	headers map[string]*fingerprintPattern
+	notHTML []*fingerprintPattern
+}
+
+var (
+	classificationRules     []*ClassificationRule
+	classificationRulesOnce sync.Once
+)
+
+// loadClassificationRules 加载页面分类规则，外部文件读取或解析失败时使用内置规则
+func loadClassificationRules() []*ClassificationRule {
+	classificationRulesOnce.Do(func() {
+		data := defaultClassifications
+		if external, err := os.ReadFile(classificationFile); err == nil {
+			data = external
+		}
+		rules, err := parseClassificationRules(data)
+		if err != nil {
+			fmt.Printf("加载页面分类规则 %s 失败，使用内置规则: %v\n", classificationFile, err)
+			rules, _ = parseClassificationRules(defaultClassifications)
+		}
+		classificationRules = rules
+		fmt.Printf("已加载 %d 条页面分类规则\n", len(rules))
+	})
+	return classificationRules
+}
+
+// parseClassificationRules 解析并编译页面分类规则
+func parseClassificationRules(data []byte) ([]*ClassificationRule, error) {
+	var rules []*ClassificationRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.Category == "" {
+			return nil, fmt.Errorf("规则缺少 category")
+		}
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// compile 编译规则中的所有模式，任一模式无效时返回包含规则分类和模式的错误
+func (r *ClassificationRule) compile() error {
+	var err error
+	lists := []struct {
+		field    string
+		patterns []string
+		compiled *[]*fingerprintPattern
+	}{
+		{"title", r.Title, &r.title},
+		{"html", r.HTML, &r.html},
+		{"text", r.Text, &r.text},
+		{"url", r.URL, &r.url},
+		{"notHtml", r.NotHTML, &r.notHTML},
+	}
+	for _, list := range lists {
+		if *list.compiled, err = compilePatternList(list.field, list.patterns); err != nil {
+			return fmt.Errorf("规则 %s: %v", r.Category, err)
+		}
+	}
+	if r.headers, err = compilePatternMap("headers", r.Headers); err != nil {
+		return fmt.Errorf("规则 %s: %v", r.Category, err)
+	}
+	if r.Label == "" {
+		r.Label = r.Category
+	}
+	return nil
+}
+
+// matches 判断截图结果是否符合该分类
+func (r *ClassificationRule) matches(result *CaptureResult, html, text string) bool {
+	if r.Blank && !result.Blank {
+		return false
+	}
+	if r.StatusMin > 0 && result.StatusCode < r.StatusMin {
+		return false
+	}
+	if r.StatusMax > 0 && (result.StatusCode == 0 || result.StatusCode > r.StatusMax) {
+		return false
+	}
+	if r.MaxTextSize != nil && result.TextSize > *r.MaxTextSize {
+		return false
+	}
+	for _, p := range r.notHTML {
+		if ok, _ := p.match(html); ok {
+			return false
+		}
+	}
+
+	hasPatterns := len(r.title)+len(r.html)+len(r.text)+len(r.url)+len(r.headers) > 0
+	if !hasPatterns {
+		return true
+	}
+	matchAny := func(patterns []*fingerprintPattern, value string) bool {
+		for _, p := range patterns {
+			if ok, _ := p.match(value); ok {
+				return true
+			}
+		}
+		return false
+	}
+	finalURL := result.URL
+	if result.FinalURL != "" {
+		finalURL = result.FinalURL
+	}
+	if matchAny(r.title, result.Title) || matchAny(r.html, html) || matchAny(r.text, text) || matchAny(r.url, finalURL) {
+		return true
+	}
+	for name, p := range r.headers {
+		if value, ok := result.Headers[name]; ok {
+			if matched, _ := p.match(value); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// classifyPage 根据DOM、可见文本和响应信息为截图结果设置页面分类，未命中任何规则时保持为空
+func classifyPage(result *CaptureResult) {
+	html := string(result.DOM)
+	text := string(result.Text)
+	for _, rule := range loadClassificationRules() {
+		if rule.matches(result, html, text) {
+			result.Category = rule.Category
+			result.CategoryLabel = rule.Label
+			return
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/inspector"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+)
+
+// 每类页面事件最多保留的条数，避免日志刷屏的页面撑爆结果记录
+const maxPageEvents = 200
+
+// ConsoleMessage 页面控制台输出
+type ConsoleMessage struct {
+	Level string `json:"level"` // log、warning、error、debug 等
+	Text  string `json:"text"`
+	Stack string `json:"stack,omitempty"`
+}
+
+// PageException 页面未捕获的JavaScript异常
+type PageException struct {
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"`
+	Line    int64  `json:"line"`
+	Column  int64  `json:"column"`
+	Stack   string `json:"stack,omitempty"`
+}
+
+// FailedRequest 加载失败的网络请求
+type FailedRequest struct {
+	URL      string `json:"url"`
+	Type     string `json:"type,omitempty"`
+	Error    string `json:"error"`
+	Canceled bool   `json:"canceled,omitempty"`
+	Blocked  string `json:"blocked,omitempty"`
+}
+
+// pageEventCollector 收集一次截图过程中的控制台输出、JS异常、失败请求和主文档响应
+type pageEventCollector struct {
+	mu          sync.Mutex
+	requestURLs map[network.RequestID]string
+	console     []ConsoleMessage
+	exceptions  []PageException
+	failed      []FailedRequest
+
+	mainFrame    cdp.FrameID
+	mainResponse *network.Response
+	crashed      bool
+}
+
+func newPageEventCollector() *pageEventCollector {
+	return &pageEventCollector{requestURLs: make(map[network.RequestID]string)}
+}
+
+// listen 作为chromedp.ListenTarget的回调，在事件循环中同步调用，不能阻塞
+func (c *pageEventCollector) listen(ev interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		c.requestURLs[ev.RequestID] = ev.Request.URL
+		// 第一个文档请求所在的frame即为主frame
+		if c.mainFrame == "" && ev.Type == network.ResourceTypeDocument {
+			c.mainFrame = ev.FrameID
+		}
+	case *network.EventResponseReceived:
+		// 主frame最后一个文档响应即为跳转后最终页面的响应
+		if ev.Type == network.ResourceTypeDocument && ev.FrameID == c.mainFrame {
+			c.mainResponse = ev.Response
+		}
+	case *inspector.EventTargetCrashed:
+		c.crashed = true
+	case *runtime.EventConsoleAPICalled:
+		if len(c.console) >= maxPageEvents {
+			return
+		}
+		args := make([]string, 0, len(ev.Args))
+		for _, arg := range ev.Args {
+			args = append(args, remoteObjectString(arg))
+		}
+		c.console = append(c.console, ConsoleMessage{
+			Level: ev.Type.String(),
+			Text:  strings.Join(args, " "),
+			Stack: formatStackTrace(ev.StackTrace),
+		})
+	case *runtime.EventExceptionThrown:
+		if len(c.exceptions) >= maxPageEvents || ev.ExceptionDetails == nil {
+			return
+		}
+		details := ev.ExceptionDetails
+		exception := PageException{
+			Message: details.Text,
+			URL:     details.URL,
+			Line:    details.LineNumber + 1,
+			Column:  details.ColumnNumber + 1,
+			Stack:   formatStackTrace(details.StackTrace),
+		}
+		// 异常对象的description通常包含 "TypeError: xxx" 及完整堆栈
+		if details.Exception != nil && details.Exception.Description != "" {
+			exception.Message = details.Exception.Description
+		}
+		c.exceptions = append(c.exceptions, exception)
+	case *network.EventLoadingFailed:
+		if len(c.failed) >= maxPageEvents {
+			return
+		}
+		c.failed = append(c.failed, FailedRequest{
+			URL:      c.requestURLs[ev.RequestID],
+			Type:     ev.Type.String(),
+			Error:    ev.ErrorText,
+			Canceled: ev.Canceled,
+			Blocked:  ev.BlockedReason.String(),
+		})
+	}
+}
+
+// apply 将收集到的事件写入截图结果
+func (c *pageEventCollector) apply(result *CaptureResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result.Console = append([]ConsoleMessage(nil), c.console...)
+	result.Exceptions = append([]PageException(nil), c.exceptions...)
+	result.FailedRequests = append([]FailedRequest(nil), c.failed...)
+	if c.mainResponse != nil {
+		result.StatusCode = c.mainResponse.Status
+		result.Headers = make(map[string]string, len(c.mainResponse.Headers))
+		for name, value := range c.mainResponse.Headers {
+			result.Headers[strings.ToLower(name)] = fmt.Sprint(value)
+		}
+	}
+}
+
+// targetCrashed 返回页面是否发生过崩溃
+func (c *pageEventCollector) targetCrashed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.crashed
+}
+
+// remoteObjectString 将控制台参数转换为可读文本
+func remoteObjectString(obj *runtime.RemoteObject) string {
+	if obj == nil {
+		return ""
+	}
+	if len(obj.Value) > 0 {
+		var str string
+		if err := json.Unmarshal([]byte(obj.Value), &str); err == nil {
+			return str
+		}
+		return string(obj.Value)
+	}
+	if obj.UnserializableValue != "" {
+		return obj.UnserializableValue.String()
+	}
+	if obj.Description != "" {
+		return obj.Description
+	}
+	return obj.Type.String()
+}
+
+// formatStackTrace 将JS调用栈格式化为多行文本
+func formatStackTrace(trace *runtime.StackTrace) string {
+	if trace == nil || len(trace.CallFrames) == 0 {
+		return ""
+	}
+	var lines []string
+	for _, frame := range trace.CallFrames {
+		name := frame.FunctionName
+		if name == "" {
+			name = "(anonymous)"
+		}
+		lines = append(lines, fmt.Sprintf("at %s (%s:%d:%d)", name, frame.URL, frame.LineNumber+1, frame.ColumnNumber+1))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 任务控制命令
+const (
+	ActionCancel      = "cancel"      // 取消单个目标（排队中或执行中）
+	ActionSkip        = "skip"        // 跳过卡住的目标，立即中止当前截图
+	ActionPrioritize  = "prioritize"  // 将排队中的目标移到队首
+	ActionConcurrency = "concurrency" // 调整并发数
+	ActionPause       = "pause"       // 暂停，执行中的目标继续完成，不再开始新目标
+	ActionResume      = "resume"      // 继续
+	ActionCancelJob   = "cancel-job"  // 取消整个任务
+)
+
+// EventControl 控制命令生效后记录的事件，让其他订阅者同步任务状态
+const EventControl = "control"
+
+// 取消原因，作为目标上下文的 cause 传给 captureScreenshot
+var (
+	errTargetCanceled = &CaptureError{Kind: ErrorCanceled, Err: errors.New("目标已被取消")}
+	errTargetSkipped  = &CaptureError{Kind: ErrorSkipped, Err: errors.New("目标已被跳过")}
+	errJobCanceled    = &CaptureError{Kind: ErrorCanceled, Err: errors.New("任务已取消")}
+)
+
+// errJobFinished 任务已结束或正在取消，不再接受控制命令
+var errJobFinished = errors.New("任务已结束")
+
+// JobCommand 任务控制命令，通过WebSocket或 POST /api/v1/jobs/{id}/control 发送
+type JobCommand struct {
+	ID          string `json:"id,omitempty"` // 客户端的请求ID，在回复中原样返回
+	Action      string `json:"action"`
+	URL         string `json:"url,omitempty"`         // cancel、skip、prioritize 的目标
+	Concurrency int    `json:"concurrency,omitempty"` // concurrency 的新并发数
+}
+
+// control 执行控制命令，user 为发出命令的用户（用于日志）
+func (j *Job) control(cmd JobCommand, user string) error {
+	jobsMutex.Lock()
+	if j.Status != JobRunning || j.canceling {
+		jobsMutex.Unlock()
+		return errJobFinished
+	}
+	jobsMutex.Unlock()
+
+	key := normalizeURL(cmd.URL)
+	data := map[string]interface{}{"action": cmd.Action}
+	switch cmd.Action {
+	case ActionCancel, ActionSkip:
+		if cmd.URL == "" {
+			return errors.New("缺少 url")
+		}
+		cause, verb := errTargetCanceled, "取消"
+		if cmd.Action == ActionSkip {
+			cause, verb = errTargetSkipped, "跳过"
+		}
+		jobsMutex.Lock()
+		cancel, running := j.cancels[key]
+		if running {
+			cancel(cause)
+		} else if j.queue.prioritize(key) {
+			// 排队中的目标移到队首，轮到时直接记为取消，不再截图
+			j.canceled[key] = cause
+		} else {
+			jobsMutex.Unlock()
+			return fmt.Errorf("%s 不在执行中或队列中", cmd.URL)
+		}
+		jobsMutex.Unlock()
+		data["url"] = key
+		data["status"] = fmt.Sprintf("已%s %s", verb, key)
+	case ActionPrioritize:
+		if !j.queue.prioritize(key) {
+			return fmt.Errorf("%s 不在队列中", cmd.URL)
+		}
+		data["url"] = key
+		data["status"] = fmt.Sprintf("%s 已移到队首", key)
+	case ActionConcurrency:
+		if cmd.Concurrency < 1 || cmd.Concurrency > maxConcurrency {
+			return fmt.Errorf("concurrency 必须在 1-%d 之间", maxConcurrency)
+		}
+		j.limiter.setLimit(cmd.Concurrency)
+		jobsMutex.Lock()
+		j.Concurrency = cmd.Concurrency
+		jobsMutex.Unlock()
+		data["concurrency"] = cmd.Concurrency
+		data["status"] = fmt.Sprintf("并发数调整为 %d", cmd.Concurrency)
+	case ActionPause, ActionResume:
+		paused := cmd.Action == ActionPause
+		j.limiter.setPaused(paused)
+		jobsMutex.Lock()
+		j.Paused = paused
+		jobsMutex.Unlock()
+		data["paused"] = paused
+		data["status"] = "已继续"
+		if paused {
+			data["status"] = "已暂停，执行中的目标完成后不再开始新目标"
+		}
+	case ActionCancelJob:
+		jobsMutex.Lock()
+		j.canceling = true
+		j.Paused = false
+		for _, cancel := range j.cancels {
+			cancel(errJobCanceled)
+		}
+		jobsMutex.Unlock()
+		// 排队中的目标轮到时直接记为取消
+		j.limiter.setPaused(false)
+		data["status"] = "正在取消任务"
+	default:
+		return fmt.Errorf("未知的命令 %q", cmd.Action)
+	}
+
+	if user != "" {
+		data["user"] = user
+		fmt.Printf("用户 %s: %s\n", user, data["status"])
+	} else {
+		fmt.Printf("%s\n", data["status"])
+	}
+	j.emit(EventControl, data)
+	return nil
+}
+
+// targetContext 为目标创建可取消的上下文并登记，目标已被取消时上下文直接以取消原因结束
+// 返回的函数在目标结束时调用
+func (j *Job) targetContext(url string) (context.Context, func()) {
+	key := normalizeURL(url)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	if j.canceling {
+		cancel(errJobCanceled)
+	} else if cause, ok := j.canceled[key]; ok {
+		delete(j.canceled, key)
+		cancel(cause)
+	} else {
+		j.cancels[key] = cancel
+	}
+	return ctx, func() {
+		jobsMutex.Lock()
+		delete(j.cancels, key)
+		jobsMutex.Unlock()
+		cancel(nil)
+	}
+}
+
+// isCanceling 任务是否正在取消
+func (j *Job) isCanceling() bool {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	return j.canceling
+}
+
+// sleepContext 等待一段时间，上下文结束时提前返回
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// waitContext 在浏览器操作中等待，上下文结束（超时、取消或跳过目标）时立即返回其错误
+func waitContext(ctx context.Context, d time.Duration) error {
+	sleepContext(ctx, d)
+	return ctx.Err()
+}
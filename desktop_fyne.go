@@ -0,0 +1,788 @@
+//go:build !windows || fyne
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// 缩略图网格中每项的大小
+const (
+	gridThumbWidth  = 240
+	gridThumbHeight = 150
+)
+
+// desktopApp Fyne原生桌面界面，与本地页面共用同一截图引擎（任务、运行目录和结果），
+// 界面状态只在界面线程中修改，后台协程通过 fyne.Do 更新
+type desktopApp struct {
+	app    fyne.App
+	window fyne.Window
+	addr   string // 本地服务器地址，浏览器中可打开同一任务的网页界面
+
+	// 目标列表和截图选项
+	targets     *widget.Entry
+	fullPage    *widget.Check
+	savePDF     *widget.Check
+	discoverSAN *widget.Check
+	singleURL   *widget.Entry
+	singleBtn   *widget.Button
+
+	// 任务控制
+	startBtn    *widget.Button
+	pauseBtn    *widget.Button
+	cancelBtn   *widget.Button
+	concurrency *widget.Select
+	progress    *widget.ProgressBar
+	status      *widget.Label
+
+	// 结果网格和详情
+	grid     *widget.GridWrap
+	detail   *fyne.Container
+	results  []*CaptureResult // 网格显示的结果：本窗口的单个截图在前，当前任务的结果在后
+	singles  []*CaptureResult
+	replaced map[string]*CaptureResult // 重新截图的任务结果：原结果ID -> 新结果
+	thumbs   map[string]fyne.Resource  // 结果ID -> 缩略图
+	loading  map[string]bool           // 正在生成缩略图的结果ID
+	selected string                    // 详情中显示的结果ID
+
+	// 全文检索
+	search     *widget.Entry
+	searchHits map[string]bool // 命中的结果ID，在网格中标出
+
+	job     *Job // 正在跟随的任务
+	syncing bool // 根据任务状态更新控件时不触发控制命令
+}
+
+// runDesktop 启动本地HTTP服务器（供浏览器和API使用），并打开Fyne桌面界面
+func runDesktop() {
+	_, addr, err := startServer(ServerConfig{Listen: defaultListenAddr, FallbackRandom: true})
+	if err != nil {
+		fmt.Printf("启动本地服务器失败: %v\n", err)
+		os.Exit(1)
+	}
+	serverAddr = addr
+	fmt.Printf("本地服务器已启动: %s\n", addr)
+
+	d := &desktopApp{
+		app:      app.NewWithID("com.webcut.ng"),
+		addr:     addr,
+		thumbs:   make(map[string]fyne.Resource),
+		loading:  make(map[string]bool),
+		replaced: make(map[string]*CaptureResult),
+	}
+	d.window = d.app.NewWindow("WebCut-网页快照")
+	d.window.SetContent(d.build())
+	d.window.Resize(fyne.NewSize(1280, 820))
+
+	// 接入已在运行的任务（如通过API提交的任务），之后定期检查网页界面或API新提交的任务
+	d.watchJobs()
+	d.setupTray()
+	d.window.ShowAndRun()
+}
+
+// setupTray 系统托盘：显示任务进度，可暂停/取消任务；关闭窗口时隐藏到托盘，从托盘菜单退出
+// 任务结束或错误率突增时发出桌面通知
+func (d *desktopApp) setupTray() {
+	icon := fyne.NewStaticResource("webcut.png", trayIconPNG())
+	d.app.SetIcon(icon)
+	desk, ok := d.app.(desktop.App)
+	if !ok {
+		return
+	}
+
+	status := fyne.NewMenuItem("没有运行中的任务", nil)
+	status.Disabled = true
+	pause := fyne.NewMenuItem("暂停", func() {
+		if err := toggleCurrentJobPause(); err != nil {
+			dialog.ShowError(err, d.window)
+		}
+	})
+	cancel := fyne.NewMenuItem("取消任务", func() {
+		if err := cancelCurrentJob(); err != nil {
+			dialog.ShowError(err, d.window)
+		}
+	})
+	show := fyne.NewMenuItem("显示窗口", func() {
+		d.window.Show()
+		d.window.RequestFocus()
+	})
+	menu := fyne.NewMenu("WebCut", status, pause, cancel, fyne.NewMenuItemSeparator(), show)
+	desk.SetSystemTrayMenu(menu)
+	desk.SetSystemTrayIcon(icon)
+	d.window.SetCloseIntercept(d.window.Hide)
+
+	monitor := &jobMonitor{
+		update: func(s trayStatus) {
+			fyne.Do(func() {
+				status.Label = s.Text
+				pause.Label = "暂停"
+				if s.Paused {
+					pause.Label = "继续"
+				}
+				pause.Disabled = !s.Running
+				cancel.Disabled = !s.Running
+				menu.Refresh()
+			})
+		},
+		notify: func(title, message string) {
+			fyne.Do(func() {
+				d.app.SendNotification(fyne.NewNotification(title, message))
+			})
+		},
+	}
+	go monitor.run()
+}
+
+// build 创建界面：左侧为目标列表和选项，中间为任务控制和缩略图网格，右侧为结果详情
+func (d *desktopApp) build() fyne.CanvasObject {
+	d.targets = widget.NewMultiLineEntry()
+	d.targets.SetPlaceHolder("每行一个URL")
+	urlListMutex.Lock()
+	d.targets.SetText(strings.Join(urlList, "\n"))
+	urlListMutex.Unlock()
+	loadBtn := widget.NewButton("打开列表文件", d.openTargetFile)
+
+	d.fullPage = widget.NewCheck("整页截图", nil)
+	d.savePDF = widget.NewCheck("保存PDF", nil)
+	d.discoverSAN = widget.NewCheck("从证书SAN发现新目标", nil)
+	options := currentSettings().Options
+	d.fullPage.SetChecked(options.FullPage)
+	d.savePDF.SetChecked(options.SavePDF)
+	d.discoverSAN.SetChecked(options.DiscoverSANs)
+
+	d.singleURL = widget.NewEntry()
+	d.singleURL.SetPlaceHolder("请输入要截图的网址")
+	d.singleURL.OnSubmitted = func(string) { d.captureSingleURL() }
+	d.singleBtn = widget.NewButton("截取屏幕", d.captureSingleURL)
+
+	left := container.NewBorder(
+		container.NewVBox(widget.NewLabel("目标列表"), loadBtn),
+		container.NewVBox(
+			d.fullPage, d.savePDF, d.discoverSAN,
+			widget.NewSeparator(),
+			widget.NewLabel("单个截图"), d.singleURL, d.singleBtn,
+		),
+		nil, nil,
+		d.targets,
+	)
+
+	d.startBtn = widget.NewButton("批量截图", d.startBatch)
+	d.pauseBtn = widget.NewButton("暂停", func() {
+		action := ActionPause
+		if d.job != nil && d.job.snapshot().Paused {
+			action = ActionResume
+		}
+		d.sendCommand(JobCommand{Action: action})
+	})
+	d.cancelBtn = widget.NewButton("取消任务", func() {
+		dialog.ShowConfirm("取消任务", "确定取消整个任务吗？", func(ok bool) {
+			if ok {
+				d.sendCommand(JobCommand{Action: ActionCancelJob})
+			}
+		}, d.window)
+	})
+	levels := make([]string, maxConcurrency)
+	for i := range levels {
+		levels[i] = strconv.Itoa(i + 1)
+	}
+	d.concurrency = widget.NewSelect(levels, func(value string) {
+		if d.syncing {
+			return
+		}
+		n, _ := strconv.Atoi(value)
+		d.sendCommand(JobCommand{Action: ActionConcurrency, Concurrency: n})
+	})
+	d.concurrency.SetSelected(strconv.Itoa(currentSettings().Concurrency))
+	d.progress = widget.NewProgressBar()
+	d.status = widget.NewLabel("就绪")
+	d.status.Truncation = fyne.TextTruncateEllipsis
+	openWeb := widget.NewButton("在浏览器中打开", func() { d.openURL(d.addr) })
+	d.setRunning(false)
+
+	d.search = widget.NewEntry()
+	d.search.SetPlaceHolder("全文检索所有运行，如 \"powered by\" server:apache-coyote，按回车检索")
+	d.search.OnSubmitted = d.runSearch
+
+	controls := container.NewVBox(
+		container.NewHBox(d.startBtn, d.pauseBtn, d.cancelBtn, widget.NewLabel("并发数"), d.concurrency, openWeb),
+		d.progress,
+		d.status,
+		d.search,
+	)
+
+	// 网格只为可见项创建控件，大量结果时也能流畅滚动
+	d.grid = widget.NewGridWrap(
+		func() int { return len(d.results) },
+		d.newGridItem,
+		d.updateGridItem,
+	)
+	d.grid.OnSelected = func(id widget.GridWrapItemID) {
+		if id >= 0 && id < len(d.results) {
+			d.showDetail(d.results[id])
+		}
+	}
+
+	d.detail = container.NewVBox(widget.NewLabel("选择截图查看详情"))
+	center := container.NewBorder(controls, nil, nil, nil, d.grid)
+	right := container.NewHSplit(center, container.NewVScroll(d.detail))
+	right.Offset = 0.65
+	split := container.NewHSplit(left, right)
+	split.Offset = 0.22
+	return split
+}
+
+// newGridItem 网格项模板：缩略图（失败时显示失败原因）、URL和状态
+func (d *desktopApp) newGridItem() fyne.CanvasObject {
+	image := canvas.NewImageFromResource(nil)
+	image.FillMode = canvas.ImageFillContain
+	image.SetMinSize(fyne.NewSize(gridThumbWidth, gridThumbHeight))
+	failed := widget.NewLabel("")
+	failed.Wrapping = fyne.TextWrapWord
+	failed.Alignment = fyne.TextAlignCenter
+	address := widget.NewLabel("")
+	address.Truncation = fyne.TextTruncateEllipsis
+	state := widget.NewLabel("")
+	state.Truncation = fyne.TextTruncateEllipsis
+	return container.NewVBox(container.NewStack(image, failed), address, state)
+}
+
+// updateGridItem 填充网格项，缩略图在后台生成后刷新该项
+func (d *desktopApp) updateGridItem(id widget.GridWrapItemID, item fyne.CanvasObject) {
+	if id < 0 || id >= len(d.results) {
+		return
+	}
+	result := d.results[id]
+	box := item.(*fyne.Container)
+	stack := box.Objects[0].(*fyne.Container)
+	image := stack.Objects[0].(*canvas.Image)
+	failed := stack.Objects[1].(*widget.Label)
+	box.Objects[1].(*widget.Label).SetText(result.URL)
+	summary := resultSummary(result)
+	if d.searchHits[result.ID] {
+		summary = "检索命中 · " + summary
+	}
+	box.Objects[2].(*widget.Label).SetText(summary)
+
+	if result.Error != "" {
+		image.Resource = nil
+		image.Hide()
+		failed.SetText("截图失败\n" + result.ErrorKind.Label())
+		failed.Show()
+		return
+	}
+	failed.Hide()
+	image.Show()
+	image.Resource = d.thumbs[result.ID]
+	image.Refresh()
+	if image.Resource == nil {
+		d.loadThumbnail(result)
+	}
+}
+
+// loadThumbnail 在后台读取截图并生成缩略图，完成后刷新网格
+func (d *desktopApp) loadThumbnail(result *CaptureResult) {
+	if d.loading[result.ID] {
+		return
+	}
+	d.loading[result.ID] = true
+	go func() {
+		var resource fyne.Resource
+		if data := resultImage(result); data != nil {
+			if thumb, err := thumbs.get(result.ID, data); err == nil {
+				resource = fyne.NewStaticResource(result.ID+".jpg", thumb)
+			}
+		}
+		fyne.Do(func() {
+			delete(d.loading, result.ID)
+			if resource == nil {
+				return
+			}
+			d.thumbs[result.ID] = resource
+			for i, r := range d.results {
+				if r.ID == result.ID {
+					d.grid.RefreshItem(i)
+				}
+			}
+		})
+	}()
+}
+
+// resultSummary 网格项的状态文字：标题、状态码或错误类型
+func resultSummary(result *CaptureResult) string {
+	var parts []string
+	if result.Title != "" {
+		parts = append(parts, result.Title)
+	}
+	if result.StatusCode > 0 {
+		parts = append(parts, fmt.Sprintf("HTTP %d", result.StatusCode))
+	}
+	if result.CategoryLabel != "" {
+		parts = append(parts, result.CategoryLabel)
+	}
+	if result.Error != "" && result.Error != result.ErrorKind.Label() {
+		parts = append(parts, result.Error)
+	}
+	if t := triages.get(result.ID); result.ID != "" && t.State != TriageUnreviewed {
+		parts = append([]string{t.StateLabel()}, parts...)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// showDetail 在右侧显示结果详情：原图、页面信息、证书和附件
+func (d *desktopApp) showDetail(result *CaptureResult) {
+	d.selected = result.ID
+	objects := []fyne.CanvasObject{}
+
+	if result.Error == "" {
+		image := canvas.NewImageFromResource(d.thumbs[result.ID])
+		image.FillMode = canvas.ImageFillContain
+		image.SetMinSize(fyne.NewSize(420, 280))
+		objects = append(objects, image)
+		// 原图较大，在后台读取后替换缩略图
+		go func(id string) {
+			data := resultImage(result)
+			if data == nil {
+				return
+			}
+			fyne.Do(func() {
+				if d.selected == id {
+					image.Resource = fyne.NewStaticResource(id+imageExt(data), data)
+					image.Refresh()
+				}
+			})
+		}(result.ID)
+	}
+
+	addLine := func(label, value string) {
+		if value == "" {
+			return
+		}
+		line := widget.NewLabel(label + ": " + value)
+		line.Wrapping = fyne.TextWrapWord
+		objects = append(objects, line)
+	}
+	addLine("URL", result.URL)
+	if result.FinalURL != result.URL {
+		addLine("跳转到", result.FinalURL)
+	}
+	addLine("标题", result.Title)
+	if result.StatusCode > 0 {
+		addLine("状态码", strconv.FormatInt(result.StatusCode, 10))
+	}
+	addLine("页面分类", result.CategoryLabel)
+	addLine("识别的产品", strings.Join(technologyLabels(result), ", "))
+	if result.Error != "" {
+		addLine("失败原因", result.ErrorKind.Label()+"："+result.Error)
+	}
+	if result.Blank {
+		addLine("空白页", result.BlankReason)
+	}
+	if cert := result.Certificate; cert != nil {
+		if anomalies := cert.Anomalies(); len(anomalies) > 0 {
+			addLine("证书", strings.Join(anomalies, "、"))
+		} else {
+			addLine("证书", fmt.Sprintf("正常，剩余 %d 天", cert.DaysLeft))
+		}
+		addLine("颁发者", cert.Issuer)
+	}
+	addLine("favicon哈希", result.FaviconMMH3)
+	if !result.CapturedAt.IsZero() {
+		addLine("截图时间", result.CapturedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	runID := resultRunID(result.ID)
+	buttons := container.NewHBox(
+		widget.NewButton("重新截图", func() { d.recapture(result) }),
+		widget.NewButton("打开网址", func() { d.openURL(result.URL) }),
+	)
+	if runID != "" {
+		buttons.Add(widget.NewButton("运行报告", func() { d.openURL(d.addr + "/runs/" + runID + "/report.html") }))
+		for _, kind := range []string{artifactPDF, artifactMHTML, artifactHAR} {
+			if name, ok := result.Artifacts[kind]; ok {
+				buttons.Add(widget.NewButton(strings.ToUpper(kind), func() { d.openURL(d.addr + "/runs/" + runID + "/" + name) }))
+			}
+		}
+	}
+	objects = append(objects, buttons)
+	if result.ID != "" {
+		objects = append(objects, d.triageForm(result))
+	}
+
+	d.detail.Objects = objects
+	d.detail.Refresh()
+}
+
+// triageForm 详情中的审阅表单：状态、标签（逗号分隔）和备注，修改后保存到运行目录
+func (d *desktopApp) triageForm(result *CaptureResult) fyne.CanvasObject {
+	t := triages.get(result.ID)
+	states := []string{TriageUnreviewed, TriageInteresting, TriageNotInteresting, TriageFollowUp}
+	labels := make([]string, len(states))
+	for i, state := range states {
+		labels[i] = triageLabels[state]
+	}
+	state := widget.NewRadioGroup(labels, nil)
+	state.Horizontal = true
+	state.Required = true
+	state.SetSelected(t.StateLabel())
+	tags := widget.NewEntry()
+	tags.SetPlaceHolder("标签，用逗号分隔")
+	tags.SetText(strings.Join(t.Tags, ", "))
+	note := widget.NewMultiLineEntry()
+	note.SetPlaceHolder("备注")
+	note.SetText(t.Note)
+
+	save := widget.NewButton("保存审阅", func() {
+		selected := TriageUnreviewed
+		for i, label := range labels {
+			if label == state.Selected {
+				selected = states[i]
+			}
+		}
+		tagList := strings.FieldsFunc(tags.Text, func(r rune) bool { return r == ',' || r == '，' })
+		noteText := note.Text
+		if _, err := triages.update(result.ID, TriageUpdate{State: &selected, Tags: &tagList, Note: &noteText}, ""); err != nil {
+			dialog.ShowError(err, d.window)
+			return
+		}
+		d.grid.Refresh()
+	})
+	return container.NewVBox(widget.NewSeparator(), widget.NewLabel("审阅"), state, tags, note, save)
+}
+
+// 详情中最多列出的检索结果数
+const maxDesktopSearchHits = 50
+
+// runSearch 在后台检索所有运行，命中的结果在网格中标出，详情中列出命中结果和摘要
+func (d *desktopApp) runSearch(query string) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		d.searchHits = nil
+		d.grid.Refresh()
+		return
+	}
+	go func() {
+		hits, err := pageIndex.search(query, nil)
+		shown := hits[:min(len(hits), maxDesktopSearchHits)]
+		for i := range shown {
+			shown[i] = pageIndex.withSnippet(shown[i])
+		}
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, d.window)
+				return
+			}
+			d.searchHits = make(map[string]bool, len(hits))
+			for _, hit := range hits {
+				d.searchHits[hit.ID] = true
+			}
+			d.grid.Refresh()
+			d.showSearchResults(query, len(hits), shown)
+		})
+	}()
+}
+
+// showSearchResults 在详情中列出检索结果，摘要中命中的部分用【】标出
+func (d *desktopApp) showSearchResults(query string, total int, hits []SearchHit) {
+	d.selected = ""
+	objects := []fyne.CanvasObject{widget.NewLabel(fmt.Sprintf("检索 %s：共 %d 个结果", query, total))}
+	for _, hit := range hits {
+		heading := widget.NewLabel(hit.URL + "（运行 " + hit.RunID + "）")
+		heading.Wrapping = fyne.TextWrapWord
+		objects = append(objects, widget.NewSeparator(), heading)
+		if hit.Title != "" {
+			objects = append(objects, widget.NewLabel(hit.Title))
+		}
+		var snippet strings.Builder
+		for _, part := range hit.Snippet {
+			if part.Match {
+				snippet.WriteString("【" + part.Text + "】")
+			} else {
+				snippet.WriteString(part.Text)
+			}
+		}
+		if snippet.Len() > 0 {
+			line := widget.NewLabel(snippet.String())
+			line.Wrapping = fyne.TextWrapWord
+			objects = append(objects, line)
+		}
+		id := hit.ID
+		objects = append(objects, widget.NewButton("查看详情", func() {
+			if result := findResult(id); result != nil {
+				d.showDetail(result)
+			}
+		}))
+	}
+	d.detail.Objects = objects
+	d.detail.Refresh()
+}
+
+// openURL 用系统浏览器打开地址
+func (d *desktopApp) openURL(address string) {
+	u, err := url.Parse(address)
+	if err != nil {
+		dialog.ShowError(err, d.window)
+		return
+	}
+	if err := d.app.OpenURL(u); err != nil {
+		dialog.ShowError(err, d.window)
+	}
+}
+
+// openTargetFile 从文本文件加载目标列表
+func (d *desktopApp) openTargetFile() {
+	open := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, d.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			dialog.ShowError(err, d.window)
+			return
+		}
+		d.targets.SetText(strings.Join(parseTargets(string(data)), "\n"))
+	}, d.window)
+	open.SetFilter(storage.NewExtensionFileFilter([]string{".txt"}))
+	open.Show()
+}
+
+// parseTargets 按行解析目标列表，忽略空行
+func parseTargets(text string) []string {
+	var targets []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			targets = append(targets, line)
+		}
+	}
+	return targets
+}
+
+// captureOptions 截图选项：设置中的默认选项加上界面上勾选的选项
+func (d *desktopApp) captureOptions() CaptureOptions {
+	opts := currentSettings().Options
+	opts.FullPage = d.fullPage.Checked
+	opts.SavePDF = d.savePDF.Checked
+	opts.DiscoverSANs = d.discoverSAN.Checked
+	return opts
+}
+
+// startBatch 保存目标列表（网页界面和API看到同一列表）并提交批量任务
+func (d *desktopApp) startBatch() {
+	targets := parseTargets(d.targets.Text)
+	urlListMutex.Lock()
+	urlList = targets
+	urlListMutex.Unlock()
+
+	job, err := startJob(targets, d.captureOptions(), "")
+	if err != nil {
+		dialog.ShowError(err, d.window)
+		return
+	}
+	go job.execute()
+	d.follow(job)
+}
+
+// sendCommand 向正在跟随的任务发送控制命令
+func (d *desktopApp) sendCommand(cmd JobCommand) {
+	if d.job == nil {
+		return
+	}
+	if err := d.job.control(cmd, ""); err != nil {
+		dialog.ShowError(err, d.window)
+	}
+}
+
+// setRunning 根据任务是否运行中启用或禁用控制按钮
+func (d *desktopApp) setRunning(running bool) {
+	if running {
+		d.startBtn.Disable()
+		d.pauseBtn.Enable()
+		d.cancelBtn.Enable()
+	} else {
+		d.startBtn.Enable()
+		d.pauseBtn.Disable()
+		d.cancelBtn.Disable()
+		d.pauseBtn.SetText("暂停")
+	}
+}
+
+// watchJobs 接入当前运行的任务，并定期检查网页界面或API提交的新任务
+func (d *desktopApp) watchJobs() {
+	if job := currentJob(); job != nil {
+		d.follow(job)
+	}
+	go func() {
+		for range time.Tick(2 * time.Second) {
+			job := currentJob()
+			if job == nil {
+				continue
+			}
+			fyne.Do(func() {
+				if d.job != job {
+					d.follow(job)
+				}
+			})
+		}
+	}()
+}
+
+// follow 跟随任务：显示进度，结果出现时刷新网格，任务结束后恢复按钮
+func (d *desktopApp) follow(job *Job) {
+	d.job = job
+	d.results = append([]*CaptureResult{}, d.singles...)
+	d.replaced = make(map[string]*CaptureResult)
+	d.grid.UnselectAll()
+	d.grid.Refresh()
+	d.progress.SetValue(0)
+	d.setRunning(true)
+
+	go func() {
+		lastID := 0
+		for {
+			events, notify, finished := job.eventsSince(lastID)
+			if len(events) > 0 {
+				lastID = events[len(events)-1].ID
+			}
+			snapshot := job.snapshot()
+			fyne.Do(func() {
+				if d.job == job {
+					d.applyEvents(events, snapshot, finished)
+				}
+			})
+			if finished {
+				return
+			}
+			<-notify
+		}
+	}()
+}
+
+// applyEvents 根据任务事件更新进度、状态和结果网格
+func (d *desktopApp) applyEvents(events []JobEvent, snapshot Job, finished bool) {
+	for _, event := range events {
+		if status, ok := event.Data["status"].(string); ok {
+			d.status.SetText(status)
+		}
+		if progress, ok := event.Data["progress"].(int); ok {
+			d.progress.SetValue(float64(progress) / 100)
+		}
+		if message, ok := event.Data["error"].(string); ok && event.Type == EventError {
+			dialog.ShowError(errors.New(message), d.window)
+		}
+	}
+
+	d.syncing = true
+	d.concurrency.SetSelected(strconv.Itoa(snapshot.Concurrency))
+	d.syncing = false
+	if snapshot.Paused {
+		d.pauseBtn.SetText("继续")
+	} else {
+		d.pauseBtn.SetText("暂停")
+	}
+
+	batchMutex.Lock()
+	jobResults := sortedResults(batchResults)
+	batchMutex.Unlock()
+	for i, result := range jobResults {
+		if replacement, ok := d.replaced[result.ID]; ok {
+			jobResults[i] = replacement
+		}
+	}
+	d.results = append(append([]*CaptureResult{}, d.singles...), jobResults...)
+	d.grid.Refresh()
+
+	if finished {
+		d.setRunning(false)
+		d.progress.SetValue(1)
+	}
+}
+
+// captureSingleURL 截图输入框中的单个URL，结果显示在网格最前面和详情中
+func (d *desktopApp) captureSingleURL() {
+	address := strings.TrimSpace(d.singleURL.Text)
+	if address == "" {
+		return
+	}
+	d.singleBtn.Disable()
+	d.status.SetText("正在截图 " + address + " ...")
+	opts := d.captureOptions()
+	go func() {
+		capture, err := captureSingle(address, opts)
+		fyne.Do(func() {
+			d.singleBtn.Enable()
+			if capture == nil {
+				dialog.ShowError(err, d.window)
+				return
+			}
+			d.status.SetText(fmt.Sprintf("已截图 %s，保存到运行 %s", capture.Result.URL, capture.RunID))
+			d.addSingle(capture.Result, nil)
+		})
+	}()
+}
+
+// recapture 重新截图结果对应的URL，新结果替换网格中的原结果
+func (d *desktopApp) recapture(old *CaptureResult) {
+	d.status.SetText("正在重新截图 " + old.URL + " ...")
+	opts := d.captureOptions()
+	go func() {
+		capture, err := captureSingle(old.URL, opts)
+		fyne.Do(func() {
+			if capture == nil {
+				dialog.ShowError(err, d.window)
+				return
+			}
+			d.status.SetText(fmt.Sprintf("已重新截图 %s，保存到运行 %s", capture.Result.URL, capture.RunID))
+			d.addSingle(capture.Result, old)
+		})
+	}()
+}
+
+// addSingle 将单个截图结果加入网格：替换原结果的位置，或放在最前面，并显示详情
+func (d *desktopApp) addSingle(result, replaces *CaptureResult) {
+	replaced := false
+	if replaces != nil {
+		for i, r := range d.results {
+			if r == replaces {
+				d.results[i] = result
+				replaced = true
+			}
+		}
+		isSingle := false
+		for i, r := range d.singles {
+			if r == replaces {
+				d.singles[i] = result
+				isSingle = true
+			}
+		}
+		// 任务结果被替换后，任务后续刷新网格时继续显示新结果
+		if !isSingle {
+			d.replaced[replaces.ID] = result
+		}
+	}
+	if !replaced {
+		d.singles = append([]*CaptureResult{result}, d.singles...)
+		d.results = append([]*CaptureResult{result}, d.results...)
+	}
+	d.grid.Refresh()
+	d.showDetail(result)
+}
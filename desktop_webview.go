@@ -0,0 +1,37 @@
+//go:build windows && !fyne
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jchv/go-webview2"
+)
+
+// runDesktop 启动本地HTTP服务器，并在WebView2窗口中显示界面页面
+func runDesktop() {
+	// 创建并启动本地HTTP服务器，默认端口被占用时改用随机端口
+	_, addr, err := startServer(ServerConfig{Listen: defaultListenAddr, FallbackRandom: true})
+	if err != nil {
+		fmt.Printf("启动本地服务器失败: %v\n", err)
+		os.Exit(1)
+	}
+	serverAddr = addr
+
+	// 创建WebView窗口（禁用调试模式）
+	w := webview2.New(false)
+	defer w.Destroy()
+
+	// 设置窗口标题
+	w.SetTitle("WebCut-网页快照")
+
+	// 加载本地服务器的HTML页面
+	w.Navigate(serverAddr)
+
+	// 托盘显示任务进度，长时间的批量任务可在后台运行
+	setupTray(w)
+
+	// 运行WebView主循环
+	w.Run()
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 运行对比参数
+const (
+	diffWidth          = 640 // 比较前将两张截图缩小到该宽度
+	diffMaxAspect      = 8   // 整页长截图只比较顶部，高度不超过宽度的该倍数
+	diffPixelTolerance = 24  // 像素任一通道的差值超过该值才视为变化，忽略压缩噪声
+	diffCellSize       = 16  // 变化像素按该大小的方格合并为变化区域
+	diffCellMinPixels  = 4   // 方格中的变化像素达到该数量才计入变化区域
+
+	// 默认的变化阈值：变化像素占比（百分比）达到该值即视为外观变化
+	defaultDiffThreshold = 1.0
+	// 两次截图dHash的汉明距离超过该值时，即使变化像素较少也视为外观变化
+	diffPerceptualThreshold = defaultClusterDistance
+)
+
+// 目标在两次运行之间的对比状态
+const (
+	DiffNew       = "new"       // 只在新运行中出现
+	DiffRemoved   = "removed"   // 只在旧运行中出现
+	DiffChanged   = "changed"   // 外观或页面信息有变化
+	DiffUnchanged = "unchanged" // 没有变化
+)
+
+// errSameRun 对比的两个运行相同
+var errSameRun = errors.New("请选择两个不同的运行进行对比")
+
+// DiffSide 对比中一侧的截图结果概要
+type DiffSide struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Title  string `json:"title,omitempty"`
+	Status string `json:"status"`
+	Image  string `json:"image,omitempty"`
+	Thumb  string `json:"thumb,omitempty"`
+}
+
+// FieldChange 页面信息的变化，technologies 另外列出新增和消失的产品
+type FieldChange struct {
+	Field   string   `json:"field"` // title、status、technologies、certificate
+	Before  string   `json:"before"`
+	After   string   `json:"after"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// DiffRegion 变化区域，坐标为叠加图中的像素位置
+type DiffRegion struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// TargetDiff 一个目标（按规范化URL匹配）在两次运行之间的对比结果
+type TargetDiff struct {
+	URL    string    `json:"url"`
+	Status string    `json:"status"`
+	Base   *DiffSide `json:"base,omitempty"`
+	Target *DiffSide `json:"target,omitempty"`
+
+	// 变化像素占比（百分比）、dHash汉明距离（-1为无法比较）、是否视为外观变化，
+	// 以及标出变化区域的叠加图
+	PixelChange        float64      `json:"pixelChange"`
+	PerceptualDistance int          `json:"perceptualDistance"`
+	VisualChanged      bool         `json:"visualChanged"`
+	Overlay            string       `json:"overlay,omitempty"`
+	Regions            []DiffRegion `json:"regions,omitempty"`
+
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// RunComparison 两次运行的对比结果，保存在新运行目录的 compare-<旧运行编号> 中
+type RunComparison struct {
+	Base        string         `json:"base"`
+	Target      string         `json:"target"`
+	Threshold   float64        `json:"threshold"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Counts      map[string]int `json:"counts"`
+	Targets     []TargetDiff   `json:"targets"`
+}
+
+// compareMutex 同一时间只进行一次对比，避免重复解码大量截图
+var compareMutex sync.Mutex
+
+// canonicalURL 规范化URL用于匹配两次运行的目标：协议和主机小写，去掉默认端口、用户信息、锚点和路径末尾的斜杠
+func canonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(raw)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// resultStatus 结果的状态：截图失败原因或HTTP状态码
+func resultStatus(result *CaptureResult) string {
+	if result.Error != "" && result.ErrorKind != ErrorHTTPStatus {
+		return "截图失败：" + result.ErrorKind.Label()
+	}
+	if result.StatusCode > 0 {
+		return strconv.FormatInt(result.StatusCode, 10)
+	}
+	return "成功"
+}
+
+// certificateSummary 证书摘要：主体、颁发者、到期时间、指纹和异常，没有证书时为空串
+func certificateSummary(cert *CertificateInfo) string {
+	if cert == nil {
+		return ""
+	}
+	summary := fmt.Sprintf("%s，颁发者 %s，有效期至 %s，SHA256 %s",
+		cert.Subject, cert.Issuer, cert.NotAfter.Format("2006-01-02"), cert.FingerprintSHA256[:min(len(cert.FingerprintSHA256), 16)])
+	if anomalies := cert.Anomalies(); len(anomalies) > 0 {
+		summary += "（" + strings.Join(anomalies, "、") + "）"
+	}
+	return summary
+}
+
+// diffSide 生成对比中一侧的概要
+func diffSide(result *CaptureResult) *DiffSide {
+	side := &DiffSide{ID: result.ID, URL: result.URL, Title: result.Title, Status: resultStatus(result)}
+	if result.Artifacts[artifactScreenshot] != "" {
+		side.Image = "/api/images/" + result.ID
+		side.Thumb = "/thumb/" + result.ID
+	}
+	return side
+}
+
+// metadataChanges 比较标题、状态、识别的产品和证书
+func metadataChanges(before, after *CaptureResult) []FieldChange {
+	var changes []FieldChange
+	if before.Title != after.Title {
+		changes = append(changes, FieldChange{Field: "title", Before: before.Title, After: after.Title})
+	}
+	if b, a := resultStatus(before), resultStatus(after); b != a {
+		changes = append(changes, FieldChange{Field: "status", Before: b, After: a})
+	}
+
+	beforeTechs, afterTechs := technologyLabels(before), technologyLabels(after)
+	change := FieldChange{Field: "technologies", Before: strings.Join(beforeTechs, ", "), After: strings.Join(afterTechs, ", ")}
+	for _, tech := range afterTechs {
+		if !containsFold(beforeTechs, tech) {
+			change.Added = append(change.Added, tech)
+		}
+	}
+	for _, tech := range beforeTechs {
+		if !containsFold(afterTechs, tech) {
+			change.Removed = append(change.Removed, tech)
+		}
+	}
+	if len(change.Added) > 0 || len(change.Removed) > 0 {
+		changes = append(changes, change)
+	}
+
+	if b, a := certificateSummary(before.Certificate), certificateSummary(after.Certificate); b != a {
+		changes = append(changes, FieldChange{Field: "certificate", Before: b, After: a})
+	}
+	return changes
+}
+
+// visualDiff 比较两张截图：缩小到相同宽度后逐像素比较（只在一张图中存在的部分算作变化），
+// 返回变化像素占比（百分比）、合并后的变化区域，以及在新截图上标出变化的叠加图
+func visualDiff(before, after []byte) (float64, []DiffRegion, *image.RGBA, error) {
+	beforeImg, _, err := image.Decode(bytes.NewReader(before))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("解码旧截图失败: %v", err)
+	}
+	afterImg, _, err := image.Decode(bytes.NewReader(after))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("解码新截图失败: %v", err)
+	}
+	a, err := scaleImage(beforeImg, diffWidth, diffMaxAspect)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("旧截图: %v", err)
+	}
+	b, err := scaleImage(afterImg, diffWidth, diffMaxAspect)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("新截图: %v", err)
+	}
+	width := max(a.Rect.Dx(), b.Rect.Dx())
+	height := max(a.Rect.Dy(), b.Rect.Dy())
+
+	cols, rows := (width+diffCellSize-1)/diffCellSize, (height+diffCellSize-1)/diffCellSize
+	cells := make([]int, cols*rows)
+	changedMask := make([]bool, width*height)
+	changed := 0
+	inside := func(img *image.RGBA, x, y int) bool {
+		return x < img.Rect.Dx() && y < img.Rect.Dy()
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			differs := true
+			if inside(a, x, y) && inside(b, x, y) {
+				i, j := a.PixOffset(x, y), b.PixOffset(x, y)
+				differs = false
+				for c := 0; c < 3; c++ {
+					if d := int(a.Pix[i+c]) - int(b.Pix[j+c]); d > diffPixelTolerance || d < -diffPixelTolerance {
+						differs = true
+						break
+					}
+				}
+			}
+			if differs {
+				changedMask[y*width+x] = true
+				cells[(y/diffCellSize)*cols+x/diffCellSize]++
+				changed++
+			}
+		}
+	}
+	regions := diffRegions(cells, cols, rows, width, height)
+
+	// 叠加图：未变化的部分调淡，变化的像素染红，变化区域加红框
+	overlay := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+			if inside(b, x, y) {
+				c = b.RGBAAt(x, y)
+			}
+			if changedMask[y*width+x] {
+				c = color.RGBA{uint8((int(c.R) + 0xe7) / 2), uint8(int(c.G) / 2), uint8(int(c.B) / 2), 0xff}
+			} else {
+				c = color.RGBA{lighten(c.R), lighten(c.G), lighten(c.B), 0xff}
+			}
+			overlay.SetRGBA(x, y, c)
+		}
+	}
+	red := color.RGBA{0xe7, 0x4c, 0x3c, 0xff}
+	for _, r := range regions {
+		for x := r.X; x < r.X+r.Width; x++ {
+			for _, y := range []int{r.Y, r.Y + 1, r.Y + r.Height - 2, r.Y + r.Height - 1} {
+				overlay.SetRGBA(x, y, red)
+			}
+		}
+		for y := r.Y; y < r.Y+r.Height; y++ {
+			for _, x := range []int{r.X, r.X + 1, r.X + r.Width - 2, r.X + r.Width - 1} {
+				overlay.SetRGBA(x, y, red)
+			}
+		}
+	}
+	return float64(changed) * 100 / float64(width*height), regions, overlay, nil
+}
+
+// lighten 将颜色向白色调淡40%
+func lighten(v uint8) uint8 {
+	return uint8(int(v) + (0xff-int(v))*2/5)
+}
+
+// diffRegions 将相邻的变化方格合并为矩形区域
+func diffRegions(cells []int, cols, rows, width, height int) []DiffRegion {
+	visited := make([]bool, len(cells))
+	var regions []DiffRegion
+	for start := range cells {
+		if visited[start] || cells[start] < diffCellMinPixels {
+			continue
+		}
+		visited[start] = true
+		minX, minY, maxX, maxY := cols, rows, -1, -1
+		queue := []int{start}
+		for len(queue) > 0 {
+			cell := queue[0]
+			queue = queue[1:]
+			cx, cy := cell%cols, cell/cols
+			minX, minY, maxX, maxY = min(minX, cx), min(minY, cy), max(maxX, cx), max(maxY, cy)
+			for _, next := range [][2]int{{cx - 1, cy}, {cx + 1, cy}, {cx, cy - 1}, {cx, cy + 1}} {
+				nx, ny := next[0], next[1]
+				if nx < 0 || ny < 0 || nx >= cols || ny >= rows {
+					continue
+				}
+				n := ny*cols + nx
+				if !visited[n] && cells[n] >= diffCellMinPixels {
+					visited[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+		x, y := minX*diffCellSize, minY*diffCellSize
+		regions = append(regions, DiffRegion{
+			X:      x,
+			Y:      y,
+			Width:  min((maxX+1)*diffCellSize, width) - x,
+			Height: min((maxY+1)*diffCellSize, height) - y,
+		})
+	}
+	return regions
+}
+
+// resultsByURL 按规范化URL索引结果，多个目标规范化后相同时使用目标序号最小（最先加入任务）的结果
+func resultsByURL(results []*CaptureResult) map[string]*CaptureResult {
+	byURL := make(map[string]*CaptureResult, len(results))
+	for _, result := range results {
+		key := canonicalURL(result.URL)
+		if existing, ok := byURL[key]; !ok || resultIDLess(result.ID, existing.ID) {
+			byURL[key] = result
+		}
+	}
+	return byURL
+}
+
+// compareDir 对比结果保存的目录
+func compareDir(base, target string) string {
+	return filepath.Join(runsDir, target, "compare-"+base)
+}
+
+// cachedComparison 读取已保存的对比结果，阈值不同或任一运行的结果记录更新过时返回nil
+func cachedComparison(base, target string, threshold float64) *RunComparison {
+	path := filepath.Join(compareDir(base, target), "comparison.json")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	for _, id := range []string{base, target} {
+		if results, err := os.Stat(filepath.Join(runsDir, id, "results.json")); err != nil || results.ModTime().After(info.ModTime()) {
+			return nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var comparison RunComparison
+	if json.Unmarshal(data, &comparison) != nil || comparison.Threshold != threshold {
+		return nil
+	}
+	return &comparison
+}
+
+// compareRuns 对比两次已完成的运行：按规范化URL匹配目标，比较截图外观（像素和感知哈希）、
+// 标题、状态、识别的产品和证书，叠加图和对比结果保存在新运行目录中
+func compareRuns(base, target string, threshold float64) (*RunComparison, error) {
+	if base == target {
+		return nil, errSameRun
+	}
+	baseResults, err := loadRunResults(base)
+	if err != nil {
+		return nil, err
+	}
+	targetResults, err := loadRunResults(target)
+	if err != nil {
+		return nil, err
+	}
+
+	compareMutex.Lock()
+	defer compareMutex.Unlock()
+	if comparison := cachedComparison(base, target, threshold); comparison != nil {
+		return comparison, nil
+	}
+	dir := compareDir(base, target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建对比目录失败: %v", err)
+	}
+	fmt.Printf("开始对比运行 %s 和 %s\n", base, target)
+
+	before, after := resultsByURL(baseResults), resultsByURL(targetResults)
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	diffs := make([]TargetDiff, len(keys))
+	var wg sync.WaitGroup
+	indexes := make(chan int)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				diffs[i] = compareTarget(keys[i], before[keys[i]], after[keys[i]], threshold, dir, base, target)
+			}
+		}()
+	}
+	for i := range keys {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	comparison := &RunComparison{
+		Base:        base,
+		Target:      target,
+		Threshold:   threshold,
+		GeneratedAt: time.Now(),
+		Counts:      map[string]int{DiffNew: 0, DiffRemoved: 0, DiffChanged: 0, DiffUnchanged: 0},
+		Targets:     diffs,
+	}
+	for _, diff := range diffs {
+		comparison.Counts[diff.Status]++
+	}
+	data, err := json.MarshalIndent(comparison, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "comparison.json"), data, 0644); err != nil {
+		fmt.Printf("保存对比结果失败: %v\n", err)
+	}
+	fmt.Printf("对比完成：新增 %d，移除 %d，变化 %d，未变 %d\n",
+		comparison.Counts[DiffNew], comparison.Counts[DiffRemoved], comparison.Counts[DiffChanged], comparison.Counts[DiffUnchanged])
+	return comparison, nil
+}
+
+// compareTarget 对比一个目标的两次结果，外观有变化时将叠加图写入对比目录
+func compareTarget(key string, before, after *CaptureResult, threshold float64, dir, base, target string) TargetDiff {
+	diff := TargetDiff{URL: key, PerceptualDistance: -1}
+	switch {
+	case before == nil:
+		diff.Status = DiffNew
+		diff.Target = diffSide(after)
+		return diff
+	case after == nil:
+		diff.Status = DiffRemoved
+		diff.Base = diffSide(before)
+		return diff
+	}
+	diff.Base, diff.Target = diffSide(before), diffSide(after)
+	diff.Changes = metadataChanges(before, after)
+	if distance, ok := hashDistance(before.PerceptualHash, after.PerceptualHash); ok {
+		diff.PerceptualDistance = distance
+	}
+
+	beforeImage := resultImage(before)
+	afterImage := resultImage(after)
+	switch {
+	case beforeImage == nil && afterImage == nil:
+		// 两次都没有截图，只比较页面信息
+	case beforeImage == nil || afterImage == nil:
+		diff.PixelChange = 100
+		diff.VisualChanged = true
+	default:
+		change, regions, overlay, err := visualDiff(beforeImage, afterImage)
+		if err != nil {
+			fmt.Printf("对比 %s 的截图失败: %v\n", key, err)
+			break
+		}
+		diff.PixelChange = float64(int(change*100)) / 100
+		diff.Regions = regions
+		diff.VisualChanged = change >= threshold || diff.PerceptualDistance > diffPerceptualThreshold
+		if diff.VisualChanged {
+			var buf bytes.Buffer
+			name := after.ID + ".png"
+			if err := png.Encode(&buf, overlay); err == nil && os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644) == nil {
+				diff.Overlay = "/runs/" + target + "/compare-" + base + "/" + name
+			} else {
+				fmt.Printf("保存 %s 的对比叠加图失败\n", key)
+			}
+		}
+	}
+
+	diff.Status = DiffUnchanged
+	if diff.VisualChanged || len(diff.Changes) > 0 {
+		diff.Status = DiffChanged
+	}
+	return diff
+}
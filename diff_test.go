@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestResultsByURL(t *testing.T) {
+	const run = "20261019-120000"
+	tests := []struct {
+		name    string
+		results []*CaptureResult
+		want    string
+	}{
+		{"序号999早于1000", []*CaptureResult{
+			{ID: run + "-1000", URL: "https://example.com/"},
+			{ID: run + "-999", URL: "HTTPS://Example.com:443"},
+		}, run + "-999"},
+		{"顺序无关", []*CaptureResult{
+			{ID: run + "-999", URL: "https://example.com"},
+			{ID: run + "-1000", URL: "https://example.com/"},
+		}, run + "-999"},
+		{"三位序号", []*CaptureResult{
+			{ID: run + "-010", URL: "https://example.com"},
+			{ID: run + "-002", URL: "https://example.com"},
+			{ID: run + "-100", URL: "https://example.com"},
+		}, run + "-002"},
+	}
+	for _, tt := range tests {
+		byURL := resultsByURL(tt.results)
+		if len(byURL) != 1 {
+			t.Errorf("%s: 分组数 = %d, want 1", tt.name, len(byURL))
+			continue
+		}
+		for _, result := range byURL {
+			if result.ID != tt.want {
+				t.Errorf("%s: 选中 %s, want %s", tt.name, result.ID, tt.want)
+			}
+		}
+	}
+}
+
+func TestResultIndex(t *testing.T) {
+	tests := map[string]int{
+		"20261019-120000-000":  0,
+		"20261019-120000-999":  999,
+		"20261019-120000-1000": 1000,
+		"20261019-120000-abc":  -1,
+		"noindex":              -1,
+	}
+	for id, want := range tests {
+		if got := resultIndex(id); got != want {
+			t.Errorf("resultIndex(%q) = %d, want %d", id, got, want)
+		}
+	}
+}
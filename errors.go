@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ErrorKind 截图失败的类型，写入结果记录和进度事件，并决定是否重试
+type ErrorKind string
+
+const (
+	ErrorDNS               ErrorKind = "dns"                // 域名解析失败
+	ErrorConnectionRefused ErrorKind = "connection-refused" // 端口未开放或连接被拒绝
+	ErrorConnection        ErrorKind = "connection"         // 连接被重置、中断或网络不可达
+	ErrorTLS               ErrorKind = "tls"                // TLS握手或证书错误
+	ErrorTimeoutNavigation ErrorKind = "timeout-navigation" // 导航超时
+	ErrorTimeoutWait       ErrorKind = "timeout-wait"       // 等待页面加载、跳转或渲染超时
+	ErrorTimeoutScreenshot ErrorKind = "timeout-screenshot" // 截图或保存页面内容超时
+	ErrorHTTPStatus        ErrorKind = "http-status"        // 服务器返回4xx/5xx状态码（仍有截图）
+	ErrorBrowserCrash      ErrorKind = "browser-crash"      // 浏览器或标签页崩溃、连接断开
+	ErrorOutOfScope        ErrorKind = "out-of-scope"       // 目标不在任务范围内，未截图
+	ErrorEmpty             ErrorKind = "empty"              // 没有得到截图数据
+	ErrorCanceled          ErrorKind = "canceled"           // 目标或任务被用户取消
+	ErrorSkipped           ErrorKind = "skipped"            // 用户跳过了卡住的目标
+	ErrorUnknown           ErrorKind = "unknown"
+)
+
+// 截图过程的阶段，用于区分超时发生的位置
+const (
+	stageNavigate   = "navigate"
+	stageWait       = "wait"
+	stageScreenshot = "screenshot"
+)
+
+// errorLabels 错误类型的中文说明
+var errorLabels = map[ErrorKind]string{
+	ErrorDNS:               "域名解析失败",
+	ErrorConnectionRefused: "连接被拒绝",
+	ErrorConnection:        "网络连接错误",
+	ErrorTLS:               "TLS/证书错误",
+	ErrorTimeoutNavigation: "导航超时",
+	ErrorTimeoutWait:       "等待页面加载超时",
+	ErrorTimeoutScreenshot: "截图超时",
+	ErrorHTTPStatus:        "HTTP错误状态码",
+	ErrorBrowserCrash:      "浏览器崩溃",
+	ErrorOutOfScope:        "不在任务范围内",
+	ErrorEmpty:             "截图数据为空",
+	ErrorCanceled:          "已取消",
+	ErrorSkipped:           "已跳过",
+	ErrorUnknown:           "未知错误",
+}
+
+// Label 返回错误类型的中文说明
+func (k ErrorKind) Label() string {
+	if label, ok := errorLabels[k]; ok {
+		return label
+	}
+	return string(k)
+}
+
+// Retryable 判断该类型的失败是否值得重试：超时、崩溃、连接中断等偶发错误重试，
+// DNS失败、端口未开放、证书错误等确定性错误重试也不会成功
+func (k ErrorKind) Retryable() bool {
+	switch k {
+	case ErrorTimeoutNavigation, ErrorTimeoutWait, ErrorTimeoutScreenshot,
+		ErrorBrowserCrash, ErrorConnection, ErrorEmpty, ErrorUnknown:
+		return true
+	}
+	return false
+}
+
+// isGatewayStatus 判断状态码是否为网关类错误（502/503/504），这类错误通常是暂时的
+func isGatewayStatus(status int64) bool {
+	return status == 502 || status == 503 || status == 504
+}
+
+// CaptureError 带类型的截图错误
+type CaptureError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *CaptureError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Kind.Label(), e.Err)
+}
+
+func (e *CaptureError) Unwrap() error {
+	return e.Err
+}
+
+// errorKindOf 返回错误的类型，非CaptureError时按错误内容推断
+func errorKindOf(err error) ErrorKind {
+	var captureErr *CaptureError
+	if errors.As(err, &captureErr) {
+		return captureErr.Kind
+	}
+	return classifyError(err, "")
+}
+
+// classifyError 根据Page.navigate返回的errorText（net::ERR_*）、上下文超时及所处阶段推断错误类型
+func classifyError(err error, stage string) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		switch stage {
+		case stageNavigate:
+			return ErrorTimeoutNavigation
+		case stageScreenshot:
+			return ErrorTimeoutScreenshot
+		}
+		return ErrorTimeoutWait
+	}
+	if errors.Is(err, chromedp.ErrChannelClosed) || errors.Is(err, chromedp.ErrInvalidTarget) ||
+		errors.Is(err, chromedp.ErrInvalidContext) {
+		return ErrorBrowserCrash
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "ERR_NAME_NOT_RESOLVED"), strings.Contains(msg, "ERR_NAME_RESOLUTION_FAILED"):
+		return ErrorDNS
+	case strings.Contains(msg, "ERR_CONNECTION_REFUSED"):
+		return ErrorConnectionRefused
+	case strings.Contains(msg, "ERR_CERT_"), strings.Contains(msg, "ERR_SSL_"),
+		strings.Contains(msg, "ERR_BAD_SSL_CLIENT_AUTH_CERT"), strings.Contains(msg, "ERR_TLS"):
+		return ErrorTLS
+	case strings.Contains(msg, "ERR_CONNECTION_TIMED_OUT"), strings.Contains(msg, "ERR_TIMED_OUT"):
+		return ErrorTimeoutNavigation
+	case strings.Contains(msg, "ERR_CONNECTION_"), strings.Contains(msg, "ERR_ADDRESS_UNREACHABLE"),
+		strings.Contains(msg, "ERR_INTERNET_DISCONNECTED"), strings.Contains(msg, "ERR_NETWORK_"),
+		strings.Contains(msg, "ERR_EMPTY_RESPONSE"), strings.Contains(msg, "ERR_PROXY_"):
+		return ErrorConnection
+	case strings.Contains(msg, "Target crashed"), strings.Contains(msg, "target closed"),
+		strings.Contains(msg, "websocket"), strings.Contains(msg, "context canceled"):
+		return ErrorBrowserCrash
+	}
+	return ErrorUnknown
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 任务事件类型
+const (
+	EventStarted   = "started"   // 任务开始
+	EventResult    = "result"    // 目标截图成功
+	EventRetry     = "retry"     // 目标即将重试，或开始最终重试轮
+	EventError     = "error"     // 目标截图失败，或任务级错误（data.error）
+	EventCompleted = "completed" // 任务完成，之后不再有事件
+)
+
+// sseKeepAlive SSE连接的心跳间隔，避免代理断开空闲连接
+const sseKeepAlive = 15 * time.Second
+
+// JobEvent 任务事件，ID在任务内从1开始单调递增，用于断线重连后补发
+type JobEvent struct {
+	ID   int                    `json:"id"`
+	Type string                 `json:"type"`
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// emit 追加任务事件并唤醒订阅者
+func (j *Job) emit(eventType string, data map[string]interface{}) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	j.appendEvent(eventType, data)
+}
+
+// appendEvent 追加事件，调用方需持有 jobsMutex
+func (j *Job) appendEvent(eventType string, data map[string]interface{}) {
+	j.events = append(j.events, JobEvent{ID: len(j.events) + 1, Type: eventType, Time: time.Now(), Data: data})
+	close(j.notify)
+	j.notify = make(chan struct{})
+}
+
+// eventsSince 返回ID大于 lastID 的事件、有新事件时关闭的通道，以及任务是否已结束
+func (j *Job) eventsSince(lastID int) ([]JobEvent, <-chan struct{}, bool) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	lastID = min(max(lastID, 0), len(j.events))
+	events := append([]JobEvent(nil), j.events[lastID:]...)
+	return events, j.notify, j.Status != JobRunning
+}
+
+// lastEventID 读取客户端已收到的最后一个事件ID：EventSource重连时的 Last-Event-ID 头，或 lastEventId 参数
+func lastEventID(r *http.Request) int {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("lastEventId")
+	}
+	id, _ := strconv.Atoi(value)
+	return id
+}
+
+// streamJobEvents 以SSE发送任务事件：先补发客户端未收到的事件，再实时发送新事件，任务完成后结束
+// 每个连接只在自己的处理协程中写入响应
+func streamJobEvents(w http.ResponseWriter, r *http.Request, job *Job) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	lastID := lastEventID(r)
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		events, notify, finished := job.eventsSince(lastID)
+		for _, event := range events {
+			data, _ := json.Marshal(event.Data)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			lastID = event.ID
+		}
+		flusher.Flush()
+		if finished {
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sortedResults 按URL排序截图结果，保证导出顺序稳定
+func sortedResults(results map[string]*CaptureResult) []*CaptureResult {
+	list := make([]*CaptureResult, 0, len(results))
+	for _, result := range results {
+		list = append(list, result)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].URL < list[j].URL })
+	return list
+}
+
+// technologyLabels 返回识别出的产品标签列表
+func technologyLabels(result *CaptureResult) []string {
+	labels := make([]string, 0, len(result.Technologies))
+	for _, tech := range result.Technologies {
+		labels = append(labels, tech.Label())
+	}
+	return labels
+}
+
+// writeResultsJSON 导出完整的截图结果记录
+func writeResultsJSON(w io.Writer, results []*CaptureResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// csvSafe 防止CSV注入：标题、URL等来自目标网站，以 = + - @ 或制表符、回车开头的单元格
+// 在Excel/LibreOffice中会被当作公式执行，加上单引号前缀作为文本显示；数字（如负的mmh3哈希）保持不变
+func csvSafe(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+// writeResultsCSV 导出截图结果摘要，便于在表格中筛选
+func writeResultsCSV(w io.Writer, results []*CaptureResult) error {
+	// 写入BOM，避免Excel打开中文乱码
+	if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	header := []string{"url", "final_url", "status", "title", "category", "technologies", "favicon_mmh3", "favicon_md5", "phash", "screenshot", "error_kind", "error", "triage", "tags", "notes"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, result := range results {
+		status := ""
+		if result.StatusCode > 0 {
+			status = strconv.FormatInt(result.StatusCode, 10)
+		}
+		var triage Triage
+		if result.Triage != nil {
+			triage = *result.Triage
+		}
+		record := []string{
+			result.URL,
+			result.FinalURL,
+			status,
+			result.Title,
+			result.CategoryLabel,
+			strings.Join(technologyLabels(result), "; "),
+			result.FaviconMMH3,
+			result.FaviconMD5,
+			result.PerceptualHash,
+			result.Artifacts[artifactScreenshot],
+			string(result.ErrorKind),
+			result.Error,
+			triage.State,
+			strings.Join(triage.Tags, "; "),
+			triage.Note,
+		}
+		for i, cell := range record {
+			record[i] = csvSafe(cell)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// favicon大小上限，超过视为异常响应
+const maxFaviconSize = 1 << 20
+
+// faviconTransport 获取favicon使用的连接，与浏览器一样忽略证书错误并使用系统代理
+var faviconTransport = &http.Transport{
+	TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	Proxy:           http.ProxyFromEnvironment,
+}
+
+// faviconCandidates 返回favicon候选地址：页面声明的图标优先，其次为站点根目录的 /favicon.ico
+func faviconCandidates(pageURL, declared string) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	var candidates []string
+	if declared != "" && !strings.HasPrefix(declared, "data:") {
+		if ref, err := base.Parse(declared); err == nil {
+			candidates = append(candidates, ref.String())
+		}
+	}
+	root := &url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/favicon.ico"}
+	if len(candidates) == 0 || candidates[0] != root.String() {
+		candidates = append(candidates, root.String())
+	}
+	return candidates
+}
+
+// fetchFavicon 下载favicon，写入结果并计算Shodan风格的mmh3哈希和MD5
+// result.FaviconURL 初始为页面<link rel=icon>声明的地址，下载后更新为实际使用的地址。
+// 只从任务范围内的主机获取（未指定范围时只从页面所在主机获取），发往目标主机的请求附带认证信息
+func fetchFavicon(result *CaptureResult, opts CaptureOptions) {
+	pageURL := result.URL
+	if result.FinalURL != "" {
+		pageURL = result.FinalURL
+	}
+	scope := opts.scope
+	if scope.Empty() {
+		scope = newScope(opts.Scope)
+	}
+	if scope.Empty() {
+		scope = newScope([]string{pageURL})
+	}
+	declared := result.FaviconURL
+	result.FaviconURL = ""
+	for _, candidate := range faviconCandidates(pageURL, declared) {
+		if !scope.AllowsURL(candidate) {
+			fmt.Printf("favicon地址 %s 不在任务范围内，跳过\n", candidate)
+			continue
+		}
+		data, contentType, err := downloadFavicon(candidate, result.URL, scope, opts.Auth)
+		if err != nil {
+			continue
+		}
+		result.FaviconURL = candidate
+		result.Favicon = data
+		result.FaviconType = contentType
+		result.FaviconMMH3 = strconv.Itoa(int(faviconMMH3(data)))
+		sum := md5.Sum(data)
+		result.FaviconMD5 = hex.EncodeToString(sum[:])
+		return
+	}
+}
+
+// downloadFavicon 下载图标，跳转到范围外的地址时放弃
+func downloadFavicon(iconURL, targetURL string, scope *Scope, auth *CaptureAuth) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", iconURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36")
+	auth.applyToRequest(req, targetURL)
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: faviconTransport,
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("跳转次数过多")
+			}
+			if !scope.AllowsURL(next.URL.String()) {
+				return fmt.Errorf("跳转到范围外的地址 %s", next.URL)
+			}
+			// 跳转到其他主机时不再附带认证信息
+			auth.stripFromRequest(next, targetURL)
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) == 0 || len(data) > maxFaviconSize {
+		return nil, "", fmt.Errorf("favicon大小异常: %d", len(data))
+	}
+	contentType := http.DetectContentType(data)
+	// 很多站点对不存在的路径返回200的HTML页面，不能当作图标
+	if strings.HasPrefix(contentType, "text/html") {
+		return nil, "", fmt.Errorf("返回的是HTML页面")
+	}
+	return data, contentType, nil
+}
+
+// faviconExt 根据内容类型选择保存的扩展名
+func faviconExt(contentType string, data []byte) string {
+	switch {
+	case strings.Contains(contentType, "png"):
+		return ".png"
+	case strings.Contains(contentType, "gif"):
+		return ".gif"
+	case strings.Contains(contentType, "jpeg"):
+		return ".jpg"
+	case strings.Contains(contentType, "svg") || strings.Contains(string(data[:min(len(data), 256)]), "<svg"):
+		return ".svg"
+	}
+	return ".ico"
+}
+
+// faviconMMH3 计算Shodan/FOFA使用的favicon哈希：
+// 对按76字符换行的base64文本（Python base64.encodebytes）做MurmurHash3 32位哈希，结果为有符号整数
+func faviconMMH3(data []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	b.WriteByte('\n')
+	return int32(murmur3Sum32([]byte(b.String()), 0))
+}
+
+// murmur3Sum32 MurmurHash3 x86 32位实现
+func murmur3Sum32(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	h := seed
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	tail := data[n*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+// MurmurHash3 x86 32位的公开测试向量
+func TestMurmur3Sum32(t *testing.T) {
+	tests := []struct {
+		data string
+		seed uint32
+		want uint32
+	}{
+		{"", 0, 0},
+		{"", 1, 0x514e28b7},
+		{"", 0xffffffff, 0x81f16f39},
+		{"\x00\x00\x00\x00", 0, 0x2362f9de},
+		{"a", 0x9747b28c, 0x7fa09ea6},
+		{"aa", 0x9747b28c, 0x5d211726},
+		{"aaa", 0x9747b28c, 0x283e0130},
+		{"aaaa", 0x9747b28c, 0x5a97808a},
+		{"abcd", 0x9747b28c, 0xf0478627},
+		{"Hello, world!", 0x9747b28c, 0x24884cba},
+		{"The quick brown fox jumps over the lazy dog", 0x9747b28c, 0x2fa826cd},
+	}
+	for _, tt := range tests {
+		if got := murmur3Sum32([]byte(tt.data), tt.seed); got != tt.want {
+			t.Errorf("murmur3Sum32(%q, %#x) = %#x, want %#x", tt.data, tt.seed, got, tt.want)
+		}
+	}
+}
+
+// 期望值按 Shodan 的算法 mmh3.hash(base64.encodebytes(data)) 计算，覆盖多行base64和负数结果
+func TestFaviconMMH3(t *testing.T) {
+	long := make([]byte, 0, 768)
+	for i := 0; i < 3; i++ {
+		for b := 0; b < 256; b++ {
+			long = append(long, byte(b))
+		}
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want int32
+	}{
+		{"多行base64", long, 1836528006},
+		{"PNG文件头", append([]byte("\x89PNG\r\n\x1a\n"), []byte("iconiconiconiconiconiconiconiconiconicon")...), 122884918},
+		{"负数哈希", []byte("GIF89a"), -851503336},
+	}
+	for _, tt := range tests {
+		if got := faviconMMH3(tt.data); got != tt.want {
+			t.Errorf("%s: faviconMMH3() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
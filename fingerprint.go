@@ -0,0 +1,317 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 外部指纹规则文件，存在时替换内置规则
+var fingerprintFile = "fingerprints.json"
+
+//go:embed fingerprints.json
+var defaultFingerprints []byte
+
+// Technology 识别出的产品/技术
+type Technology struct {
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// Label 返回带版本号的名称，用于画廊标签和导出
+func (t Technology) Label() string {
+	if t.Version == "" {
+		return t.Name
+	}
+	return t.Name + " " + t.Version
+}
+
+// FingerprintRule 指纹规则，格式参考Wappalyzer：
+// 每个模式是正则表达式，可追加 "\;version:\1" 从捕获组提取版本号
+// 任一字段命中即识别为该产品
+type FingerprintRule struct {
+	Name     string            `json:"name"`
+	Category string            `json:"category"`
+	Headers  map[string]string `json:"headers"` // 响应头名称 -> 值模式（空模式表示只要求存在）
+	Cookies  map[string]string `json:"cookies"` // Cookie名称 -> 名称存在即命中（值暂不参与匹配）
+	Meta     map[string]string `json:"meta"`    // meta标签name/property -> content模式
+	Scripts  []string          `json:"scripts"` // 脚本src模式
+	HTML     []string          `json:"html"`    // 渲染后DOM模式
+	Title    []string          `json:"title"`   // 页面标题模式
+	URL      []string          `json:"url"`     // 最终URL模式
+	Favicon  []string          `json:"favicon"` // favicon哈希（Shodan风格mmh3或MD5）
+	Implies  []string          `json:"implies"` // 命中后同时推断出的产品
+
+	headers map[string]*fingerprintPattern
+	meta    map[string]*fingerprintPattern
+	scripts []*fingerprintPattern
+	html    []*fingerprintPattern
+	title   []*fingerprintPattern
+	url     []*fingerprintPattern
+}
+
+// fingerprintPattern 编译后的模式及版本提取模板
+type fingerprintPattern struct {
+	re      *regexp.Regexp
+	version string
+}
+
+// fingerprintInput 指纹识别的输入，来自一次截图结果
+type fingerprintInput struct {
+	URL         string
+	Title       string
+	HTML        string
+	Headers     map[string]string
+	Cookies     []string
+	Meta        map[string]string
+	Scripts     []string
+	FaviconHash []string
+}
+
+// fingerprintInputFor 从截图结果构造指纹识别输入
+func fingerprintInputFor(result *CaptureResult) fingerprintInput {
+	input := fingerprintInput{
+		URL:     result.URL,
+		Title:   result.Title,
+		HTML:    string(result.DOM),
+		Headers: result.Headers,
+		Cookies: result.Cookies,
+		Meta:    result.Meta,
+		Scripts: result.Scripts,
+	}
+	if result.FinalURL != "" {
+		input.URL = result.FinalURL
+	}
+	if result.FaviconMMH3 != "" {
+		input.FaviconHash = []string{result.FaviconMMH3, result.FaviconMD5}
+	}
+	return input
+}
+
+var (
+	fingerprintRules     []*FingerprintRule
+	fingerprintRulesOnce sync.Once
+)
+
+// loadFingerprintRules 加载指纹规则，外部文件读取或解析失败时使用内置规则
+func loadFingerprintRules() []*FingerprintRule {
+	fingerprintRulesOnce.Do(func() {
+		data := defaultFingerprints
+		if external, err := os.ReadFile(fingerprintFile); err == nil {
+			data = external
+		}
+		rules, err := parseFingerprintRules(data)
+		if err != nil {
+			fmt.Printf("加载指纹规则 %s 失败，使用内置规则: %v\n", fingerprintFile, err)
+			rules, _ = parseFingerprintRules(defaultFingerprints)
+		}
+		fingerprintRules = rules
+		fmt.Printf("已加载 %d 条指纹规则\n", len(rules))
+	})
+	return fingerprintRules
+}
+
+// parseFingerprintRules 解析并编译指纹规则
+func parseFingerprintRules(data []byte) ([]*FingerprintRule, error) {
+	var rules []*FingerprintRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// compile 编译规则中的所有模式，任一模式无效时返回包含规则名称和模式的错误
+func (r *FingerprintRule) compile() error {
+	var err error
+	if r.headers, err = compilePatternMap("headers", r.Headers); err != nil {
+		return fmt.Errorf("规则 %s: %v", r.Name, err)
+	}
+	if r.meta, err = compilePatternMap("meta", r.Meta); err != nil {
+		return fmt.Errorf("规则 %s: %v", r.Name, err)
+	}
+	lists := []struct {
+		field    string
+		patterns []string
+		compiled *[]*fingerprintPattern
+	}{
+		{"scripts", r.Scripts, &r.scripts},
+		{"html", r.HTML, &r.html},
+		{"title", r.Title, &r.title},
+		{"url", r.URL, &r.url},
+	}
+	for _, list := range lists {
+		if *list.compiled, err = compilePatternList(list.field, list.patterns); err != nil {
+			return fmt.Errorf("规则 %s: %v", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// compilePatternList 编译模式列表，遇到第一个无效的模式即返回错误
+func compilePatternList(field string, patterns []string) ([]*fingerprintPattern, error) {
+	compiled := make([]*fingerprintPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		p, err := compileFingerprintPattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s 模式 %q 无效: %v", field, pattern, err)
+		}
+		compiled = append(compiled, p)
+	}
+	return compiled, nil
+}
+
+// compilePatternMap 编译按名称（不区分大小写）匹配的模式，遇到第一个无效的模式即返回错误
+func compilePatternMap(field string, patterns map[string]string) (map[string]*fingerprintPattern, error) {
+	compiled := make(map[string]*fingerprintPattern, len(patterns))
+	for key, pattern := range patterns {
+		p, err := compileFingerprintPattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%s] 模式 %q 无效: %v", field, key, pattern, err)
+		}
+		compiled[strings.ToLower(key)] = p
+	}
+	return compiled, nil
+}
+
+// compileFingerprintPattern 编译 "正则\;version:\1" 形式的模式，匹配不区分大小写
+func compileFingerprintPattern(pattern string) (*fingerprintPattern, error) {
+	parts := strings.Split(pattern, `\;`)
+	p := &fingerprintPattern{}
+	for _, part := range parts[1:] {
+		if strings.HasPrefix(part, "version:") {
+			p.version = strings.TrimPrefix(part, "version:")
+		}
+	}
+	re, err := regexp.Compile("(?i)" + parts[0])
+	if err != nil {
+		return nil, err
+	}
+	p.re = re
+	return p, nil
+}
+
+// match 匹配文本，返回是否命中及提取的版本号
+func (p *fingerprintPattern) match(text string) (bool, string) {
+	groups := p.re.FindStringSubmatch(text)
+	if groups == nil {
+		return false, ""
+	}
+	if p.version == "" {
+		return true, ""
+	}
+	version := p.version
+	for i := len(groups) - 1; i >= 1; i-- {
+		version = strings.ReplaceAll(version, `\`+strconv.Itoa(i), groups[i])
+	}
+	return true, strings.TrimSpace(version)
+}
+
+// matchRule 判断单条规则是否命中
+func (r *FingerprintRule) matchRule(input fingerprintInput) (bool, string) {
+	matched := false
+	version := ""
+	check := func(p *fingerprintPattern, text string) {
+		if ok, v := p.match(text); ok {
+			matched = true
+			if version == "" {
+				version = v
+			}
+		}
+	}
+
+	for name, p := range r.headers {
+		if value, ok := input.Headers[name]; ok {
+			check(p, value)
+		}
+	}
+	for name, p := range r.meta {
+		if value, ok := input.Meta[name]; ok {
+			check(p, value)
+		}
+	}
+	for name := range r.Cookies {
+		for _, cookie := range input.Cookies {
+			if strings.EqualFold(cookie, name) {
+				matched = true
+			}
+		}
+	}
+	for _, p := range r.scripts {
+		for _, src := range input.Scripts {
+			check(p, src)
+		}
+	}
+	for _, p := range r.html {
+		check(p, input.HTML)
+	}
+	for _, p := range r.title {
+		check(p, input.Title)
+	}
+	for _, p := range r.url {
+		check(p, input.URL)
+	}
+	for _, hash := range r.Favicon {
+		for _, h := range input.FaviconHash {
+			if h != "" && strings.EqualFold(strings.TrimSpace(hash), h) {
+				matched = true
+			}
+		}
+	}
+	return matched, version
+}
+
+// identifyTechnologies 对截图结果运行所有指纹规则，返回按名称排序的识别结果
+func identifyTechnologies(input fingerprintInput) []Technology {
+	rules := loadFingerprintRules()
+	found := make(map[string]Technology)
+	byName := make(map[string]*FingerprintRule, len(rules))
+	for _, rule := range rules {
+		byName[rule.Name] = rule
+	}
+
+	var addImplied func(rule *FingerprintRule)
+	addImplied = func(rule *FingerprintRule) {
+		for _, implied := range rule.Implies {
+			if _, ok := found[implied]; ok {
+				continue
+			}
+			tech := Technology{Name: implied}
+			if impliedRule := byName[implied]; impliedRule != nil {
+				tech.Category = impliedRule.Category
+				found[implied] = tech
+				addImplied(impliedRule)
+			} else {
+				found[implied] = tech
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		if ok, version := rule.matchRule(input); ok {
+			if existing, ok := found[rule.Name]; ok && existing.Version != "" {
+				continue
+			}
+			found[rule.Name] = Technology{Name: rule.Name, Version: version, Category: rule.Category}
+			addImplied(rule)
+		}
+	}
+
+	technologies := make([]Technology, 0, len(found))
+	for _, tech := range found {
+		technologies = append(technologies, tech)
+	}
+	sort.Slice(technologies, func(i, j int) bool { return technologies[i].Name < technologies[j].Name })
+	return technologies
+}
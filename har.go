@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+)
+
+// 默认的HAR响应体大小上限
+const defaultHARMaxBodySize = 1 << 20
+
+// harSecretHeaders HAR中始终隐藏值的请求头和响应头（小写），截图选项中的自定义请求头另外加入
+var harSecretHeaders = []string{"authorization", "proxy-authorization", "cookie", "set-cookie"}
+
+// HAR 1.2 格式，参见 http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Pages   []HARPage  `json:"pages"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HARPage struct {
+	StartedDateTime string         `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     HARPageTimings `json:"pageTimings"`
+}
+
+type HARPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+type HAREntry struct {
+	PageRef         string      `json:"pageref"`
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	ResourceType    string      `json:"_resourceType,omitempty"`
+	Error           string      `json:"_error,omitempty"` // 请求失败时Chrome给出的错误，如 net::ERR_NAME_NOT_RESOLVED
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int64          `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// harRequestState 记录一个请求从发出到结束的CDP事件
+type harRequestState struct {
+	requestID network.RequestID
+	request   *network.Request
+	resType   network.ResourceType
+	wallTime  time.Time
+	started   float64 // 单调时钟，秒
+	response  *network.Response
+	finished  float64
+	encoded   float64
+	errorText string
+	redirect  string // 重定向目标URL，非空表示该条目是重定向链中的一环
+	done      bool
+	body      []byte
+	bodyB64   bool // 二进制响应体以base64保存
+	bodyNote  string
+}
+
+// harRecorder 在一次截图过程中收集Network事件，生成HAR文件
+type harRecorder struct {
+	mu          sync.Mutex
+	entries     []*harRequestState
+	active      map[network.RequestID]*harRequestState
+	withBodies  bool
+	maxBodySize int64
+	redact      map[string]bool // 需要隐藏值的头部名称（小写）
+}
+
+func newHARRecorder(opts CaptureOptions) *harRecorder {
+	maxBodySize := opts.HARMaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultHARMaxBodySize
+	}
+	redact := make(map[string]bool)
+	for _, name := range harSecretHeaders {
+		redact[name] = true
+	}
+	if opts.Auth != nil {
+		for name := range opts.Auth.Headers {
+			redact[strings.ToLower(name)] = true
+		}
+	}
+	return &harRecorder{
+		active:      make(map[network.RequestID]*harRequestState),
+		withBodies:  opts.HARBodies,
+		maxBodySize: maxBodySize,
+		redact:      redact,
+	}
+}
+
+// monoSeconds 将CDP单调时间转换为秒，便于与ResourceTiming.RequestTime比较
+func monoSeconds(t *cdp.MonotonicTime) float64 {
+	if t == nil || cdp.MonotonicTimeEpoch == nil {
+		return 0
+	}
+	return t.Time().Sub(*cdp.MonotonicTimeEpoch).Seconds()
+}
+
+// listen 作为chromedp.ListenTarget的回调，在事件循环中同步调用，不能阻塞
+func (h *harRecorder) listen(ev interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		// 同一个RequestID再次出现且带有重定向响应，说明上一跳已结束
+		if prev, ok := h.active[ev.RequestID]; ok && ev.RedirectResponse != nil {
+			prev.response = ev.RedirectResponse
+			prev.redirect = ev.Request.URL
+			prev.finished = monoSeconds(ev.Timestamp)
+			prev.done = true
+		}
+		state := &harRequestState{
+			requestID: ev.RequestID,
+			request:   ev.Request,
+			resType:   ev.Type,
+			started:   monoSeconds(ev.Timestamp),
+			wallTime:  time.Now(),
+		}
+		if ev.WallTime != nil {
+			state.wallTime = ev.WallTime.Time()
+		}
+		h.active[ev.RequestID] = state
+		h.entries = append(h.entries, state)
+	case *network.EventResponseReceived:
+		if state, ok := h.active[ev.RequestID]; ok {
+			state.response = ev.Response
+		}
+	case *network.EventLoadingFinished:
+		if state, ok := h.active[ev.RequestID]; ok {
+			state.finished = monoSeconds(ev.Timestamp)
+			state.encoded = ev.EncodedDataLength
+			state.done = true
+		}
+	case *network.EventLoadingFailed:
+		if state, ok := h.active[ev.RequestID]; ok {
+			state.finished = monoSeconds(ev.Timestamp)
+			state.errorText = ev.ErrorText
+			if ev.BlockedReason != "" {
+				state.errorText += " (" + ev.BlockedReason.String() + ")"
+			}
+			state.done = true
+		}
+	}
+}
+
+// fetchBodies 在页面会话中读取已完成请求的响应体，超过大小上限的跳过
+func (h *harRecorder) fetchBodies(ctx context.Context) error {
+	if !h.withBodies {
+		return nil
+	}
+	h.mu.Lock()
+	var pending []*harRequestState
+	for _, state := range h.active {
+		if state.done && state.errorText == "" && state.response != nil {
+			pending = append(pending, state)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, state := range pending {
+		var body []byte
+		tooLarge := int64(state.encoded) > h.maxBodySize
+		if !tooLarge {
+			// 重定向、缓存命中等请求没有响应体，直接跳过
+			var err error
+			body, err = network.GetResponseBody(state.requestID).Do(ctx)
+			if err != nil {
+				continue
+			}
+			tooLarge = int64(len(body)) > h.maxBodySize
+		}
+
+		h.mu.Lock()
+		if tooLarge {
+			state.bodyNote = fmt.Sprintf("响应体超过 %d 字节，未保存", h.maxBodySize)
+		} else {
+			state.body = body
+			state.bodyB64 = !utf8.Valid(body)
+		}
+		h.mu.Unlock()
+	}
+	return nil
+}
+
+// build 生成HAR结构
+func (h *harRecorder) build(title string) *HAR {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := make([]HAREntry, 0, len(h.entries))
+	var pageStarted time.Time
+	for _, state := range h.entries {
+		if state.request == nil || strings.HasPrefix(state.request.URL, "data:") {
+			continue
+		}
+		if pageStarted.IsZero() || state.wallTime.Before(pageStarted) {
+			pageStarted = state.wallTime
+		}
+		entries = append(entries, state.toEntry(h.redact))
+	}
+	if pageStarted.IsZero() {
+		pageStarted = time.Now()
+	}
+
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "WebCut-NG", Version: "1.0"},
+		Pages: []HARPage{{
+			StartedDateTime: pageStarted.UTC().Format(time.RFC3339Nano),
+			ID:              "page_1",
+			Title:           title,
+			PageTimings:     HARPageTimings{OnContentLoad: -1, OnLoad: -1},
+		}},
+		Entries: entries,
+	}}
+}
+
+// marshal 生成HAR JSON
+func (h *harRecorder) marshal(title string) []byte {
+	data, err := json.MarshalIndent(h.build(title), "", "  ")
+	if err != nil {
+		fmt.Printf("生成HAR失败: %v\n", err)
+		return nil
+	}
+	return data
+}
+
+// toEntry 生成HAR条目，redact 中的头部只保留名称，值替换为 redactedValue；Cookie列表不记录
+func (s *harRequestState) toEntry(redact map[string]bool) HAREntry {
+	entry := HAREntry{
+		PageRef:         "page_1",
+		StartedDateTime: s.wallTime.UTC().Format(time.RFC3339Nano),
+		ResourceType:    strings.ToLower(s.resType.String()),
+		Error:           s.errorText,
+		Timings:         HARTimings{Blocked: -1, DNS: -1, Connect: -1, Send: 0, Wait: 0, Receive: 0, SSL: -1},
+	}
+	if s.finished > 0 && s.finished >= s.started {
+		entry.Time = (s.finished - s.started) * 1000
+	}
+
+	// 请求部分
+	entry.Request = HARRequest{
+		Method:      s.request.Method,
+		URL:         s.request.URL + s.request.URLFragment,
+		HTTPVersion: "",
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(s.request.Headers, redact),
+		QueryString: harQueryString(s.request.URL),
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+	if s.request.HasPostData {
+		var text strings.Builder
+		for _, part := range s.request.PostDataEntries {
+			if decoded, err := base64.StdEncoding.DecodeString(part.Bytes); err == nil {
+				text.Write(decoded)
+			}
+		}
+		entry.Request.PostData = &HARPostData{
+			MimeType: headerValue(s.request.Headers, "Content-Type"),
+			Text:     text.String(),
+		}
+		entry.Request.BodySize = int64(text.Len())
+	}
+
+	// 响应部分，失败的请求没有响应，状态码为0
+	entry.Response = HARResponse{
+		Cookies:     []HARNameValue{},
+		Headers:     []HARNameValue{},
+		RedirectURL: s.redirect,
+		HeadersSize: -1,
+		BodySize:    -1,
+		Content:     HARContent{Size: 0, MimeType: "x-unknown"},
+	}
+	if resp := s.response; resp != nil {
+		entry.Response.Status = resp.Status
+		entry.Response.StatusText = resp.StatusText
+		entry.Response.HTTPVersion = resp.Protocol
+		entry.Response.Headers = harHeaders(resp.Headers, redact)
+		entry.Response.Content.MimeType = resp.MimeType
+		entry.Response.BodySize = int64(s.encoded)
+		entry.Request.HTTPVersion = resp.Protocol
+		if len(resp.RequestHeaders) > 0 {
+			// 实际发送的请求头更准确
+			entry.Request.Headers = harHeaders(resp.RequestHeaders, redact)
+		}
+		if resp.RemoteIPAddress != "" {
+			entry.ServerIPAddress = strings.Trim(resp.RemoteIPAddress, "[]")
+		}
+		if resp.Timing != nil {
+			entry.Timings = harTimings(resp.Timing, s.finished)
+		}
+	}
+	if s.body != nil {
+		entry.Response.Content.Size = int64(len(s.body))
+		if s.bodyB64 {
+			entry.Response.Content.Text = base64.StdEncoding.EncodeToString(s.body)
+			entry.Response.Content.Encoding = "base64"
+		} else {
+			entry.Response.Content.Text = string(s.body)
+		}
+	}
+	entry.Response.Content.Comment = s.bodyNote
+	return entry
+}
+
+// harTimings 根据ResourceTiming计算HAR各阶段耗时（毫秒），未发生的阶段为-1
+func harTimings(t *network.ResourceTiming, finished float64) HARTimings {
+	timings := HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	span := func(start, end float64) float64 {
+		if start < 0 || end < 0 {
+			return -1
+		}
+		return end - start
+	}
+	timings.DNS = span(t.DNSStart, t.DNSEnd)
+	timings.Connect = span(t.ConnectStart, t.ConnectEnd)
+	timings.SSL = span(t.SslStart, t.SslEnd)
+	for _, first := range []float64{t.DNSStart, t.ConnectStart, t.SendStart} {
+		if first >= 0 {
+			timings.Blocked = first
+			break
+		}
+	}
+	timings.Send = max(t.SendEnd-t.SendStart, 0)
+	timings.Wait = max(t.ReceiveHeadersEnd-t.SendEnd, 0)
+	if finished > 0 {
+		timings.Receive = max((finished-t.RequestTime)*1000-t.ReceiveHeadersEnd, 0)
+	}
+	return timings
+}
+
+// harHeaders 将CDP请求头转换为有序的HAR名值对，redact 中的头部隐藏值
+func harHeaders(headers network.Headers, redact map[string]bool) []HARNameValue {
+	result := make([]HARNameValue, 0, len(headers))
+	for name, value := range headers {
+		if redact[strings.ToLower(name)] {
+			result = append(result, HARNameValue{Name: name, Value: redactedValue})
+			continue
+		}
+		// 同名多值的头部在CDP中以换行分隔
+		for _, v := range strings.Split(fmt.Sprint(value), "\n") {
+			result = append(result, HARNameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// headerValue 不区分大小写地读取请求头
+func headerValue(headers network.Headers, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func harQueryString(rawURL string) []HARNameValue {
+	result := []HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return result
+	}
+	for name, values := range u.Query() {
+		for _, v := range values {
+			result = append(result, HARNameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
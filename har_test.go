@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestHARRedactsSecretHeaders(t *testing.T) {
+	const secret = "s3cr3t-token-value"
+	opts := CaptureOptions{Auth: &CaptureAuth{
+		Cookie:  "session=" + secret,
+		Headers: map[string]string{"X-Api-Key": secret},
+	}}
+	har := newHARRecorder(opts)
+	secretHeaders := network.Headers{
+		"Authorization":       "Bearer " + secret,
+		"Proxy-Authorization": "Basic " + secret,
+		"Cookie":              "session=" + secret,
+		"x-api-key":           secret,
+		"Accept":              "text/html",
+	}
+	har.listen(&network.EventRequestWillBeSent{
+		RequestID: "1",
+		Request:   &network.Request{URL: "https://example.com/", Method: "GET", Headers: secretHeaders},
+		Type:      network.ResourceTypeDocument,
+	})
+	har.listen(&network.EventResponseReceived{
+		RequestID: "1",
+		Response: &network.Response{
+			URL:            "https://example.com/",
+			Status:         200,
+			Headers:        network.Headers{"Set-Cookie": "session=" + secret, "Server": "nginx"},
+			RequestHeaders: secretHeaders,
+		},
+	})
+	har.listen(&network.EventLoadingFinished{RequestID: "1"})
+
+	data := har.marshal("test")
+	if bytes.Contains(data, []byte(secret)) {
+		t.Fatalf("HAR中包含认证信息:\n%s", data)
+	}
+
+	var parsed HAR
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Log.Entries) != 1 {
+		t.Fatalf("条目数 = %d, want 1", len(parsed.Log.Entries))
+	}
+	entry := parsed.Log.Entries[0]
+	values := make(map[string]string)
+	for _, h := range append(entry.Request.Headers, entry.Response.Headers...) {
+		values[h.Name] = h.Value
+	}
+	for name, want := range map[string]string{
+		"Authorization":       redactedValue,
+		"Proxy-Authorization": redactedValue,
+		"Cookie":              redactedValue,
+		"x-api-key":           redactedValue,
+		"Set-Cookie":          redactedValue,
+		"Accept":              "text/html",
+		"Server":              "nginx",
+	} {
+		if got := values[name]; got != want {
+			t.Errorf("头部 %s = %q, want %q", name, got, want)
+		}
+	}
+	if len(entry.Request.Cookies) != 0 || len(entry.Response.Cookies) != 0 {
+		t.Errorf("HAR不应记录Cookie列表: %+v %+v", entry.Request.Cookies, entry.Response.Cookies)
+	}
+}
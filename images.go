@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// 缩略图宽度，网格中每列最小300px，留出高分屏余量
+	thumbWidth = 480
+	// 整页长截图的缩略图只保留顶部，高度不超过宽度的该倍数
+	thumbMaxAspect = 2
+	// 分页查询结果的默认和最大条数
+	defaultResultsLimit = 100
+	maxResultsLimit     = 1000
+)
+
+// errEmptyImage 图片宽度或高度为0（如截图失败时保存的空图片），无法缩放
+var errEmptyImage = errors.New("图片尺寸为0")
+
+// imageETag 根据图片内容生成强ETag
+func imageETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// serveImage 返回图片二进制数据，带按内容计算的ETag，浏览器重新验证时内容未变返回304
+func serveImage(w http.ResponseWriter, r *http.Request, data []byte) {
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("ETag", imageETag(data))
+	// 同一ID的截图可能在最终重试轮中被替换，每次使用前重新验证
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// thumbnailCache 缩略图缓存，键为结果ID和原图ETag
+type thumbnailCache struct {
+	mu     sync.Mutex
+	thumbs map[string][]byte
+}
+
+// thumbs 缩略图缓存，新的批量任务开始时清空
+var thumbs = newThumbnailCache()
+
+func newThumbnailCache() *thumbnailCache {
+	return &thumbnailCache{thumbs: make(map[string][]byte)}
+}
+
+// get 返回缓存的缩略图，不存在时生成
+func (c *thumbnailCache) get(id string, data []byte) ([]byte, error) {
+	key := id + imageETag(data)
+	c.mu.Lock()
+	thumb, ok := c.thumbs[key]
+	c.mu.Unlock()
+	if ok {
+		return thumb, nil
+	}
+	thumb, err := makeThumbnail(data, thumbWidth)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.thumbs[key] = thumb
+	c.mu.Unlock()
+	return thumb, nil
+}
+
+// reset 清空缓存，新的批量任务开始时调用
+func (c *thumbnailCache) reset() {
+	c.mu.Lock()
+	c.thumbs = make(map[string][]byte)
+	c.mu.Unlock()
+}
+
+// makeThumbnail 将截图按区域平均缩小到指定宽度并编码为JPEG，小图不放大
+func makeThumbnail(data []byte, width int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	thumb, err := scaleImage(img, width, thumbMaxAspect)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleImage 将图片按区域平均缩小到指定宽度，小图不放大；
+// 高度超过宽度的 maxAspect 倍时只保留顶部，宽度或高度为0时返回 errEmptyImage
+func scaleImage(img image.Image, width, maxAspect int) (*image.RGBA, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() <= 0 || bounds.Dy() <= 0 {
+		return nil, errEmptyImage
+	}
+	srcWidth := bounds.Dx()
+	srcHeight := min(bounds.Dy(), srcWidth*maxAspect)
+	if srcWidth < width {
+		width = srcWidth
+	}
+	height := max(srcHeight*width/srcWidth, 1)
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for ty := 0; ty < height; ty++ {
+		y0 := bounds.Min.Y + ty*srcHeight/height
+		y1 := max(bounds.Min.Y+(ty+1)*srcHeight/height, y0+1)
+		for tx := 0; tx < width; tx++ {
+			x0 := bounds.Min.X + tx*srcWidth/width
+			x1 := max(bounds.Min.X+(tx+1)*srcWidth/width, x0+1)
+			var r, g, b, count uint32
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					pr, pg, pb, _ := img.At(x, y).RGBA()
+					r += pr >> 8
+					g += pg >> 8
+					b += pb >> 8
+					count++
+				}
+			}
+			i := scaled.PixOffset(tx, ty)
+			scaled.Pix[i] = uint8(r / count)
+			scaled.Pix[i+1] = uint8(g / count)
+			scaled.Pix[i+2] = uint8(b / count)
+			scaled.Pix[i+3] = 0xff
+		}
+	}
+	return scaled, nil
+}
+
+// pageParams 解析分页参数 offset 和 limit
+func pageParams(r *http.Request) (offset, limit int) {
+	offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	offset = max(offset, 0)
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultResultsLimit
+	}
+	return offset, min(limit, maxResultsLimit)
+}
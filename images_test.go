@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScaleImage(t *testing.T) {
+	tests := []struct {
+		name         string
+		bounds       image.Rectangle
+		width        int
+		wantW, wantH int
+		empty        bool
+	}{
+		{"宽度为0", image.Rect(0, 0, 0, 100), thumbWidth, 0, 0, true},
+		{"高度为0", image.Rect(0, 0, 100, 0), thumbWidth, 0, 0, true},
+		{"空图片", image.Rect(0, 0, 0, 0), thumbWidth, 0, 0, true},
+		{"小图不放大", image.Rect(0, 0, 200, 100), thumbWidth, 200, 100, false},
+		{"按宽度缩小", image.Rect(0, 0, 960, 540), thumbWidth, 480, 270, false},
+		{"长截图只保留顶部", image.Rect(0, 0, 960, 10000), thumbWidth, 480, 960, false},
+		{"非零原点", image.Rect(10, 20, 970, 560), thumbWidth, 480, 270, false},
+		{"1x1", image.Rect(0, 0, 1, 1), thumbWidth, 1, 1, false},
+	}
+	for _, tt := range tests {
+		scaled, err := scaleImage(image.NewRGBA(tt.bounds), tt.width, thumbMaxAspect)
+		if tt.empty {
+			if !errors.Is(err, errEmptyImage) {
+				t.Errorf("%s: err = %v, want errEmptyImage", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := scaled.Rect.Size(); got.X != tt.wantW || got.Y != tt.wantH {
+			t.Errorf("%s: 尺寸 = %v, want %dx%d", tt.name, got, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestServeImageETag(t *testing.T) {
+	data := []byte("\x89PNG\r\n\x1a\nfake image data")
+	rec := httptest.NewRecorder()
+	serveImage(rec, httptest.NewRequest("GET", "/api/images/x", nil), data)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag != imageETag(data) {
+		t.Fatalf("状态码 = %d, ETag = %q, want 200, %q", rec.Code, etag, imageETag(data))
+	}
+
+	req := httptest.NewRequest("GET", "/api/images/x", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	serveImage(rec, req, data)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("内容未变时状态码 = %d, want 304", rec.Code)
+	}
+
+	// 同一ID的截图被替换后ETag随内容变化
+	rec = httptest.NewRecorder()
+	serveImage(rec, req, append(data, '!'))
+	if rec.Code != http.StatusOK {
+		t.Errorf("内容变化后状态码 = %d, want 200", rec.Code)
+	}
+}
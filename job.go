@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// JobStatus 批量任务状态
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Job 一次批量截图任务，界面（/batch-capture）和API（/api/v1/jobs）提交的任务都由它执行
+type Job struct {
+	ID          string         `json:"id"` // 与运行目录编号相同
+	Status      JobStatus      `json:"status"`
+	Total       int            `json:"total"`     // 目标总数，包括证书SAN发现的目标
+	Completed   int            `json:"completed"` // 已完成的目标数（包括失败的）
+	Failed      int            `json:"failed"`
+	Concurrency int            `json:"concurrency"`
+	Paused      bool           `json:"paused,omitempty"`
+	Options     CaptureOptions `json:"options"`
+	StartedAt   time.Time      `json:"startedAt"`
+	FinishedAt  *time.Time     `json:"finishedAt,omitempty"`
+	Report      string         `json:"report,omitempty"`
+	Owner       string         `json:"owner,omitempty"` // 提交任务的用户，未启用认证时为空
+
+	run     *Run
+	targets []string
+	failed  map[string]bool // 当前失败的目标（标准化URL），最终重试轮成功后移除
+	events  []JobEvent      // 任务事件记录，供订阅者补发
+	notify  chan struct{}   // 有新事件时关闭并替换
+
+	// 运行中的控制：目标队列、并发限制、执行中目标的取消函数、排队中已取消的目标
+	queue     *targetQueue
+	limiter   *concurrencyLimiter
+	cancels   map[string]context.CancelCauseFunc
+	canceled  map[string]error
+	canceling bool
+}
+
+var (
+	jobs         []*Job // 本次启动以来提交的任务，按提交顺序
+	jobsMutex    sync.Mutex
+	batchRunning bool
+)
+
+var (
+	errBatchRunning = errors.New("批量截图任务正在运行，请稍后再试")
+	errNoTargets    = errors.New("URL列表为空，请先加载URL列表")
+)
+
+// startJob 创建批量任务：确认没有任务在运行，重置浏览器池、创建运行目录并清空上次的结果
+func startJob(urls []string, opts CaptureOptions, owner string) (*Job, error) {
+	if len(urls) == 0 {
+		return nil, errNoTargets
+	}
+	if err := opts.Retry.validate(); err != nil {
+		return nil, err
+	}
+	jobsMutex.Lock()
+	if batchRunning {
+		jobsMutex.Unlock()
+		return nil, errBatchRunning
+	}
+	batchRunning = true
+	jobsMutex.Unlock()
+
+	if owner != "" {
+		fmt.Printf("用户 %s 开始批量截图，共 %d 个URL\n", owner, len(urls))
+	} else {
+		fmt.Printf("开始批量截图，共 %d 个URL\n", len(urls))
+	}
+
+	// 在开始新的批量截图任务前，重置浏览器池，解决URL列表切换后截图失败的问题
+	// 这会创建全新的浏览器实例，避免使用可能已损坏的上下文；正在进行的单个截图继续使用旧实例直到完成
+	resetBrowserPool()
+
+	// 为本次任务创建运行目录，截图和附件都保存在其中
+	run, err := newRun()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		jobsMutex.Lock()
+		batchRunning = false
+		jobsMutex.Unlock()
+		return nil, err
+	}
+	fmt.Printf("本次截图结果保存到: %s\n", run.Dir)
+
+	// 清空之前的批量截图结果
+	batchMutex.Lock()
+	batchScreenshots = make(map[string][]byte)
+	batchResults = make(map[string]*CaptureResult)
+	batchResultsByID = make(map[string]*CaptureResult)
+	batchRunID = run.ID
+	batchMutex.Unlock()
+	thumbs.reset()
+
+	concurrency := currentSettings().Concurrency
+	job := &Job{
+		ID:          run.ID,
+		Status:      JobRunning,
+		Total:       len(urls),
+		Concurrency: concurrency,
+		Options:     opts,
+		StartedAt:   run.StartedAt,
+		Owner:       owner,
+		run:         run,
+		targets:     urls,
+		failed:      make(map[string]bool),
+		notify:      make(chan struct{}),
+		queue:       newTargetQueue(urls),
+		limiter:     newConcurrencyLimiter(concurrency),
+		cancels:     make(map[string]context.CancelCauseFunc),
+		canceled:    make(map[string]error),
+	}
+	jobsMutex.Lock()
+	jobs = append(jobs, job)
+	job.appendEvent(EventStarted, map[string]interface{}{
+		"progress": 0,
+		"status":   fmt.Sprintf("开始批量截图，共 %d 个URL", len(urls)),
+		"total":    len(urls),
+		"runId":    run.ID,
+	})
+	jobsMutex.Unlock()
+	return job, nil
+}
+
+// snapshot 返回任务状态的副本，选项中的认证信息已隐藏
+func (j *Job) snapshot() Job {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	snapshot := *j
+	snapshot.Options.Auth = snapshot.Options.Auth.redacted()
+	return snapshot
+}
+
+// findJob 按ID查找本次启动以来提交的任务
+func findJob(id string) *Job {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	for _, job := range jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// listJobs 返回所有任务状态，最新的在前，选项中的认证信息已隐藏
+func listJobs() []Job {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	list := make([]Job, 0, len(jobs))
+	for i := len(jobs) - 1; i >= 0; i-- {
+		job := *jobs[i]
+		job.Options.Auth = job.Options.Auth.redacted()
+		list = append(list, job)
+	}
+	return list
+}
+
+// currentJob 返回正在运行的任务
+func currentJob() *Job {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	if len(jobs) > 0 && jobs[len(jobs)-1].Status == JobRunning {
+		return jobs[len(jobs)-1]
+	}
+	return nil
+}
+
+// execute 执行批量任务，每个目标完成时记录任务事件，结束后生成运行报告
+func (j *Job) execute() {
+	defer func() {
+		jobsMutex.Lock()
+		batchRunning = false
+		jobsMutex.Unlock()
+	}()
+	run := j.run
+	req := j.Options
+
+	// 目标队列，执行过程中证书SAN发现的范围内主机会追加到队列
+	queue := j.queue
+	scope := newScope(req.Scope)
+	// 显式指定范围时，列表中不在范围内的目标不截图
+	explicitScope := !scope.Empty()
+	if !explicitScope {
+		scope = defaultScope(j.targets)
+	}
+
+	req.scope = scope
+
+	// 创建完成的URL通道，用于实时获取已完成的截图
+	completedURLs := make(chan string, len(j.targets))
+	var wg sync.WaitGroup
+
+	// 并发数量取自设置，运行中可通过控制命令调整
+	limiter := j.limiter
+
+	// progress 在进度事件中附带该URL结果的错误类型，更新任务计数后记录为 result 或 error 事件
+	progress := func(data map[string]interface{}, url string, completed int) {
+		batchMutex.Lock()
+		result := batchResults[normalizeURL(url)]
+		failed := false
+		if result != nil && result.ErrorKind != "" {
+			data["errorKind"] = result.ErrorKind
+			data["errorLabel"] = result.ErrorKind.Label()
+			failed = result.Error != ""
+			data["failed"] = failed
+		}
+		batchMutex.Unlock()
+
+		jobsMutex.Lock()
+		if failed {
+			j.failed[normalizeURL(url)] = true
+		} else {
+			delete(j.failed, normalizeURL(url))
+		}
+		j.Failed = len(j.failed)
+		j.Total = queue.size()
+		j.Completed = max(j.Completed, completed)
+		if failed {
+			j.appendEvent(EventError, data)
+		} else {
+			j.appendEvent(EventResult, data)
+		}
+		jobsMutex.Unlock()
+	}
+
+	// 失败且错误类型可重试的目标，用于任务结束后的最终重试轮
+	var failedMutex sync.Mutex
+	var failedTargets []queuedTarget
+
+	// captureTarget 截图单个目标并保存结果，opts 中的等待方式决定重试等待期间如何让出并发名额
+	captureTarget := func(index int, url string, opts CaptureOptions) error {
+		fmt.Printf("正在截图URL: %s\n", url)
+
+		// 捕获截图
+		var result *CaptureResult
+		var err error
+		if explicitScope && !scope.AllowsURL(url) {
+			err = &CaptureError{Kind: ErrorOutOfScope, Err: fmt.Errorf("%s 不在任务范围内", url)}
+			result = &CaptureResult{URL: normalizeURL(url), Error: err.Error(), ErrorKind: ErrorOutOfScope, CapturedAt: time.Now()}
+		} else if cause := opts.canceled(); cause != nil {
+			// 排队中被取消的目标不再截图
+			err = cause
+			result = &CaptureResult{URL: normalizeURL(url), Error: err.Error(), ErrorKind: errorKindOf(err), CapturedAt: time.Now()}
+		} else {
+			result, err = captureScreenshot(url, opts)
+		}
+
+		// 证书SAN中范围内的主机加入队列
+		if req.DiscoverSANs && result != nil {
+			for _, candidate := range sanTargets(result.URL, result.Certificate) {
+				if scope.AllowsURL(candidate) && queue.push(candidate) {
+					fmt.Printf("从 %s 的证书发现新目标: %s\n", url, candidate)
+					result.Discovered = append(result.Discovered, candidate)
+				}
+			}
+		}
+
+		if err != nil {
+			fmt.Printf("URL %s 截图失败: %v\n", url, err)
+			// 失败结果同样写入运行目录（如HAR），便于排查；没有截图，由界面和报告按失败状态显示
+			run.saveResult(index, result)
+			batchMutex.Lock()
+			delete(batchScreenshots, normalizeURL(url))
+			batchResults[normalizeURL(url)] = result
+			batchResultsByID[result.ID] = result
+			batchMutex.Unlock()
+		} else {
+			// 写入运行目录，再标准化URL格式并保存截图结果
+			run.saveResult(index, result)
+			result.ImageURL = "/api/images/" + result.ID
+			result.ThumbURL = "/thumb/" + result.ID
+			batchMutex.Lock()
+			batchScreenshots[normalizeURL(url)] = result.Image
+			batchResults[normalizeURL(url)] = result
+			batchResultsByID[result.ID] = result
+			batchMutex.Unlock()
+
+			fmt.Printf("URL %s 截图成功\n", url)
+		}
+		return err
+	}
+
+	// 启动并发截图：先取目标再获取令牌，避免等待队列时占用令牌
+	go func() {
+		for {
+			target, ok := queue.pop()
+			if !ok {
+				break
+			}
+			limiter.acquire() // 获取令牌
+			wg.Add(1)
+			go func(index int, url string) {
+				defer wg.Done()
+				defer queue.done()
+				defer func() {
+					limiter.release() // 释放令牌
+					// 确保即使发生panic也能处理
+					if r := recover(); r != nil {
+						fmt.Printf("处理URL %s 时发生panic: %v\n", url, r)
+					}
+				}() // 释放令牌
+
+				// 目标可被取消或跳过；重试等待期间释放令牌，让其他目标先截图
+				ctx, done := j.targetContext(url)
+				defer done()
+				opts := req
+				opts.ctx = ctx
+				opts.sleep = func(d time.Duration) {
+					j.emit(EventRetry, map[string]interface{}{
+						"url":    normalizeURL(url),
+						"delay":  d.Milliseconds(),
+						"status": fmt.Sprintf("%s 截图失败，%.1f 秒后重试", url, d.Seconds()),
+					})
+					limiter.release()
+					sleepContext(ctx, d)
+					limiter.acquire()
+				}
+				if err := captureTarget(index, url, opts); err != nil && req.Retry.FinalPass && req.Retry.shouldRetry(errorKindOf(err)) {
+					failedMutex.Lock()
+					failedTargets = append(failedTargets, queuedTarget{Index: index, URL: url})
+					failedMutex.Unlock()
+				}
+
+				// 发送原始URL到通道，用于准确计数
+				completedURLs <- url
+			}(target.Index, target.URL)
+		}
+		// 等待所有截图任务完成
+		wg.Wait()
+		close(completedURLs)
+	}()
+
+	// 监听完成的URL并更新进度，通道关闭时所有截图任务已完成
+	processedURLs := make(map[string]bool) // 用于跟踪已处理的原始URL
+	for originalUrl := range completedURLs {
+		// 对原始URL进行计数，确保每个URL都被正确计数
+		processedURLs[originalUrl] = true
+		processedCount := len(processedURLs)
+		totalCount := queue.size()
+		// 发送进度更新和已完成的URL信息（标准化后的URL），失败时附带错误类型
+		progress(map[string]interface{}{
+			"progress":     int(float64(processedCount) / float64(totalCount) * 100),
+			"status":       fmt.Sprintf("已完成 %d/%d 个URL的截图", processedCount, totalCount),
+			"completedUrl": normalizeURL(originalUrl),
+		}, originalUrl, processedCount)
+	}
+
+	// 最终重试轮：以较低并发再次截图失败的目标，过载设备恢复后往往能成功
+	// 与第一轮共用并发限制，暂停和调整并发数的控制命令同样生效
+	if len(failedTargets) > 0 && !j.isCanceling() {
+		jobsMutex.Lock()
+		retryConcurrency := min(j.Concurrency, req.Retry.finalPassConcurrency())
+		j.Concurrency = retryConcurrency
+		jobsMutex.Unlock()
+		limiter.setLimit(retryConcurrency)
+		fmt.Printf("开始最终重试轮，共 %d 个失败目标，并发 %d\n", len(failedTargets), retryConcurrency)
+		j.emit(EventRetry, map[string]interface{}{
+			"progress":    100,
+			"status":      fmt.Sprintf("开始最终重试轮，共 %d 个失败目标", len(failedTargets)),
+			"finalPass":   true,
+			"count":       len(failedTargets),
+			"concurrency": retryConcurrency,
+		})
+		retried := make(chan string, len(failedTargets))
+		var retryWg sync.WaitGroup
+		for _, target := range failedTargets {
+			retryWg.Add(1)
+			go func(index int, url string) {
+				defer retryWg.Done()
+				limiter.acquire()
+				defer func() {
+					limiter.release()
+					if r := recover(); r != nil {
+						fmt.Printf("重试URL %s 时发生panic: %v\n", url, r)
+					}
+					retried <- url
+				}()
+				ctx, done := j.targetContext(url)
+				defer done()
+				opts := req
+				opts.ctx = ctx
+				opts.sleep = func(d time.Duration) {
+					limiter.release()
+					sleepContext(ctx, d)
+					limiter.acquire()
+				}
+				captureTarget(index, url, opts)
+			}(target.Index, target.URL)
+		}
+		go func() {
+			retryWg.Wait()
+			close(retried)
+		}()
+
+		retriedCount := 0
+		for url := range retried {
+			retriedCount++
+			progress(map[string]interface{}{
+				"progress":     100,
+				"status":       fmt.Sprintf("最终重试轮 %d/%d", retriedCount, len(failedTargets)),
+				"completedUrl": normalizeURL(url),
+				"retried":      true,
+			}, url, 0)
+		}
+	}
+
+	// 生成运行报告，链接截图及PDF/MHTML附件
+	if err := run.writeReport(); err != nil {
+		fmt.Printf("%v\n", err)
+		j.emit(EventError, map[string]interface{}{"error": err.Error()})
+	}
+
+	// 更新任务状态并记录完成事件，两者在同一次加锁中完成，订阅者看到任务结束时一定已收到完成事件
+	totalCount := queue.size()
+	finishedAt := time.Now()
+	status := fmt.Sprintf("已完成 %d/%d 个URL的截图", totalCount, totalCount)
+	jobsMutex.Lock()
+	j.Status = JobCompleted
+	if j.canceling {
+		j.Status = JobCanceled
+		status = fmt.Sprintf("任务已取消，%d 个URL中 %d 个失败或被取消", totalCount, j.Failed)
+	}
+	j.Paused = false
+	j.Total = totalCount
+	j.Completed = totalCount
+	j.FinishedAt = &finishedAt
+	j.Report = run.reportURL()
+	j.appendEvent(EventCompleted, map[string]interface{}{
+		"progress":     100,
+		"status":       status,
+		"allCompleted": true,
+		"canceled":     j.Status == JobCanceled,
+		"runId":        run.ID,
+		"report":       run.reportURL(),
+		"failedCount":  j.Failed,
+	})
+	jobsMutex.Unlock()
+
+	fmt.Println("批量截图任务完成")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"sort"
+	"strconv"
+)
+
+// 默认的相似阈值：两张截图dHash的汉明距离不超过该值即视为同一类页面
+const defaultClusterDistance = 10
+
+// perceptualHash 计算截图的64位差值哈希（dHash），返回16位十六进制字符串，解码失败时返回空串
+// 将图片缩小为9x8灰度图，比较每行相邻像素的亮度，对压缩质量、尺寸和细微文字差异不敏感
+func perceptualHash(data []byte) string {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	gray := shrinkGray(img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// shrinkGray 将图片按区域平均缩小为 width x height 的灰度矩阵
+// 大图只在每个区域内抽样，避免整页长截图逐像素计算
+func shrinkGray(img image.Image, width, height int) [][]float64 {
+	const maxSamples = 16
+	bounds := img.Bounds()
+	gray := make([][]float64, height)
+	for cy := 0; cy < height; cy++ {
+		gray[cy] = make([]float64, width)
+		y0 := bounds.Min.Y + cy*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(cy+1)*bounds.Dy()/height, y0+1)
+		stepY := max((y1-y0)/maxSamples, 1)
+		for cx := 0; cx < width; cx++ {
+			x0 := bounds.Min.X + cx*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(cx+1)*bounds.Dx()/width, x0+1)
+			stepX := max((x1-x0)/maxSamples, 1)
+			var sum float64
+			var count int
+			for y := y0; y < y1 && y < bounds.Max.Y; y += stepY {
+				for x := x0; x < x1 && x < bounds.Max.X; x += stepX {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					count++
+				}
+			}
+			if count > 0 {
+				gray[cy][cx] = sum / float64(count)
+			}
+		}
+	}
+	return gray
+}
+
+// hashDistance 计算两个十六进制哈希的汉明距离，任一哈希无效时返回false
+func hashDistance(a, b string) (int, bool) {
+	x, err := strconv.ParseUint(a, 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	y, err := strconv.ParseUint(b, 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return bits.OnesCount64(x ^ y), true
+}
+
+// ResultCluster 外观相似的一组截图，以第一张作为代表
+type ResultCluster struct {
+	Representative string   `json:"representative"`
+	Hash           string   `json:"hash"`
+	Count          int      `json:"count"`
+	Members        []string `json:"members"`
+}
+
+// clusterResults 按感知哈希的汉明距离对截图结果分组，成员多的分组排在前面
+// 没有截图（失败）的结果不参与分组
+func clusterResults(results []*CaptureResult, distance int) []*ResultCluster {
+	var clusters []*ResultCluster
+	for _, result := range results {
+		if result.PerceptualHash == "" {
+			continue
+		}
+		var matched *ResultCluster
+		for _, cluster := range clusters {
+			if d, ok := hashDistance(cluster.Hash, result.PerceptualHash); ok && d <= distance {
+				matched = cluster
+				break
+			}
+		}
+		if matched == nil {
+			matched = &ResultCluster{Representative: result.URL, Hash: result.PerceptualHash}
+			clusters = append(clusters, matched)
+		}
+		matched.Members = append(matched.Members, result.URL)
+		matched.Count++
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].Count > clusters[j].Count })
+	return clusters
+}
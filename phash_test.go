@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+// testPNG 生成 width x height 的灰度PNG，像素亮度由 shade 决定
+func testPNG(t *testing.T, width, height int, shade func(x, y int) uint8) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{Y: shade(x, y)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPerceptualHash(t *testing.T) {
+	// 棋盘式的块状图案，作为基准页面
+	page := func(x, y int) uint8 { return uint8((x/40*37 + y/30*91) % 256) }
+	base := testPNG(t, 360, 240, page)
+	// 同一页面更大的尺寸、仅一小块区域不同（如验证码、时间戳）
+	resized := testPNG(t, 720, 480, func(x, y int) uint8 { return page(x/2, y/2) })
+	tweaked := testPNG(t, 360, 240, func(x, y int) uint8 {
+		if x < 20 && y < 20 {
+			return 255
+		}
+		return page(x, y)
+	})
+	// 左右镜像后布局不同，相邻块的明暗关系基本相反
+	mirrored := testPNG(t, 360, 240, func(x, y int) uint8 { return page(359-x, y) })
+	rising := testPNG(t, 360, 240, func(x, y int) uint8 { return uint8(x * 255 / 359) })
+	falling := testPNG(t, 360, 240, func(x, y int) uint8 { return uint8(255 - x*255/359) })
+
+	hash := perceptualHash(base)
+	if len(hash) != 16 {
+		t.Fatalf("perceptualHash() = %q, 应为16位十六进制", hash)
+	}
+	if got := perceptualHash(rising); got != "0000000000000000" {
+		t.Errorf("亮度从左到右递增的图片哈希 = %s, want 0000000000000000", got)
+	}
+	if got := perceptualHash(falling); got != "ffffffffffffffff" {
+		t.Errorf("亮度从左到右递减的图片哈希 = %s, want ffffffffffffffff", got)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		maxDist int
+		minDist int
+	}{
+		{"相同图片", base, 0, 0},
+		{"放大两倍", resized, 2, 0},
+		{"局部变化", tweaked, defaultClusterDistance, 0},
+		{"不同页面", mirrored, 64, defaultClusterDistance + 1},
+	}
+	for _, tt := range tests {
+		d, ok := hashDistance(hash, perceptualHash(tt.data))
+		if !ok {
+			t.Errorf("%s: 哈希无效", tt.name)
+			continue
+		}
+		if d < tt.minDist || d > tt.maxDist {
+			t.Errorf("%s: 距离 = %d, want [%d, %d]", tt.name, d, tt.minDist, tt.maxDist)
+		}
+	}
+
+	if got := perceptualHash([]byte("not an image")); got != "" {
+		t.Errorf("无效图片的哈希 = %q, want 空串", got)
+	}
+}
+
+func TestHashDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+		ok   bool
+	}{
+		{"0000000000000000", "0000000000000000", 0, true},
+		{"0000000000000000", "ffffffffffffffff", 64, true},
+		{"00000000000000ff", "000000000000000f", 4, true},
+		{"8000000000000001", "0000000000000000", 2, true},
+		{"", "0000000000000000", 0, false},
+		{"zzzz", "0000000000000000", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := hashDistance(tt.a, tt.b)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("hashDistance(%q, %q) = %d, %v, want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestClusterResults(t *testing.T) {
+	results := []*CaptureResult{
+		{URL: "https://a.example.com", PerceptualHash: "ffff000000000000"},
+		{URL: "https://b.example.com", PerceptualHash: "0000000000000000"},
+		{URL: "https://c.example.com", PerceptualHash: "0000000000000003"}, // 与 b 距离 2
+		{URL: "https://d.example.com"},                                     // 截图失败
+		{URL: "https://e.example.com", PerceptualHash: "00000000000003ff"}, // 与 b 距离 10
+		{URL: "https://f.example.com", PerceptualHash: "fffe000000000000"}, // 与 a 距离 1
+		{URL: "https://g.example.com", PerceptualHash: "0000ffff00000000"}, // 单独一类
+	}
+	tests := []struct {
+		distance int
+		want     []ResultCluster
+	}{
+		{defaultClusterDistance, []ResultCluster{
+			{Representative: "https://b.example.com", Hash: "0000000000000000", Count: 3,
+				Members: []string{"https://b.example.com", "https://c.example.com", "https://e.example.com"}},
+			{Representative: "https://a.example.com", Hash: "ffff000000000000", Count: 2,
+				Members: []string{"https://a.example.com", "https://f.example.com"}},
+			{Representative: "https://g.example.com", Hash: "0000ffff00000000", Count: 1,
+				Members: []string{"https://g.example.com"}},
+		}},
+		{0, []ResultCluster{
+			{Representative: "https://a.example.com", Hash: "ffff000000000000", Count: 1, Members: []string{"https://a.example.com"}},
+			{Representative: "https://b.example.com", Hash: "0000000000000000", Count: 1, Members: []string{"https://b.example.com"}},
+			{Representative: "https://c.example.com", Hash: "0000000000000003", Count: 1, Members: []string{"https://c.example.com"}},
+			{Representative: "https://e.example.com", Hash: "00000000000003ff", Count: 1, Members: []string{"https://e.example.com"}},
+			{Representative: "https://f.example.com", Hash: "fffe000000000000", Count: 1, Members: []string{"https://f.example.com"}},
+			{Representative: "https://g.example.com", Hash: "0000ffff00000000", Count: 1, Members: []string{"https://g.example.com"}},
+		}},
+	}
+	for _, tt := range tests {
+		clusters := clusterResults(results, tt.distance)
+		got := make([]ResultCluster, len(clusters))
+		for i, cluster := range clusters {
+			got[i] = *cluster
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("clusterResults(distance=%d) = %+v, want %+v", tt.distance, got, tt.want)
+		}
+	}
+}
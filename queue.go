@@ -0,0 +1,139 @@
+package main
+
+import "sync"
+
+// queuedTarget 队列中的一个截图目标
+type queuedTarget struct {
+	Index int    // 目标序号，用于生成运行目录中的文件名
+	URL   string // 原始URL
+}
+
+// targetQueue 批量截图的目标队列，执行过程中可以追加新目标（如证书SAN发现的主机）
+type targetQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	pending  []queuedTarget
+	seen     map[string]bool
+	total    int
+	inFlight int
+}
+
+func newTargetQueue(urls []string) *targetQueue {
+	q := &targetQueue{seen: make(map[string]bool)}
+	q.cond = sync.NewCond(&q.mu)
+	for _, url := range urls {
+		q.push(url)
+	}
+	return q
+}
+
+// push 追加目标，按标准化URL去重，返回是否为新目标
+func (q *targetQueue) push(url string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := normalizeURL(url)
+	if q.seen[key] {
+		return false
+	}
+	q.seen[key] = true
+	q.pending = append(q.pending, queuedTarget{Index: q.total, URL: url})
+	q.total++
+	q.cond.Broadcast()
+	return true
+}
+
+// pop 取出下一个目标；队列为空但仍有目标在执行时会等待（执行中的目标可能追加新目标）
+// 队列为空且没有执行中的目标时返回false
+func (q *targetQueue) pop() (queuedTarget, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.pending) == 0 && q.inFlight > 0 {
+		q.cond.Wait()
+	}
+	if len(q.pending) == 0 {
+		return queuedTarget{}, false
+	}
+	target := q.pending[0]
+	q.pending = q.pending[1:]
+	q.inFlight++
+	return target, true
+}
+
+// done 标记一个目标执行完成
+func (q *targetQueue) done() {
+	q.mu.Lock()
+	q.inFlight--
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// size 返回目前为止的目标总数（包括追加的目标）
+func (q *targetQueue) size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.total
+}
+
+// prioritize 将排队中的目标移到队首，返回目标是否在队列中
+func (q *targetQueue) prioritize(url string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := normalizeURL(url)
+	for i, target := range q.pending {
+		if normalizeURL(target.URL) == key {
+			copy(q.pending[1:i+1], q.pending[:i])
+			q.pending[0] = target
+			return true
+		}
+	}
+	return false
+}
+
+// concurrencyLimiter 并发限制，运行中可以调整上限或暂停（暂停时不再开始新目标）
+type concurrencyLimiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+	paused bool
+}
+
+func newConcurrencyLimiter(limit int) *concurrencyLimiter {
+	l := &concurrencyLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire 获取名额，暂停或达到上限时等待
+func (l *concurrencyLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.paused || l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+// release 释放名额
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	l.active--
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// setLimit 调整并发上限，降低时已在执行的目标不受影响
+func (l *concurrencyLimiter) setLimit(limit int) {
+	l.mu.Lock()
+	l.limit = limit
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// setPaused 暂停或继续
+func (l *concurrencyLimiter) setPaused(paused bool) {
+	l.mu.Lock()
+	l.paused = paused
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
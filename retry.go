@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// 重试策略的默认值
+const (
+	defaultMaxRetries           = 1
+	defaultRetryBaseDelay       = 1000  // 毫秒
+	defaultRetryMaxDelay        = 30000 // 毫秒
+	defaultRetryJitter          = 0.3
+	defaultRetryTimeoutStep     = 5 // 秒
+	defaultFinalPassConcurrency = 2
+
+	// 重试次数上限，避免一个目标长时间占用浏览器
+	maxRetryLimit = 10
+)
+
+// errInvalidOptions 截图选项校验失败，接口返回400
+var errInvalidOptions = errors.New("截图选项无效")
+
+// RetryPolicy 截图失败的重试策略，未设置的字段使用默认值
+type RetryPolicy struct {
+	MaxRetries  *int        `json:"maxRetries"`  // 失败后的重试次数，默认1（共2次尝试）
+	BaseDelay   int         `json:"baseDelay"`   // 第一次重试前的等待（毫秒），之后每次翻倍
+	MaxDelay    int         `json:"maxDelay"`    // 重试等待上限（毫秒）
+	Jitter      *float64    `json:"jitter"`      // 等待时间随机抖动比例（0-1），避免同时重试压垮目标，默认0.3，为0时不抖动
+	TimeoutStep int         `json:"timeoutStep"` // 每次重试增加的超时时间（秒）
+	RetryOn     []ErrorKind `json:"retryOn"`     // 可重试的错误类型，为空时重试超时、崩溃、连接中断等偶发错误
+
+	// 批量任务结束后以较低并发再次截图仍失败的目标
+	FinalPass            bool `json:"finalPass"`
+	FinalPassConcurrency int  `json:"finalPassConcurrency"`
+}
+
+// validate 校验重试次数和抖动比例的范围
+func (p RetryPolicy) validate() error {
+	if p.MaxRetries != nil && (*p.MaxRetries < 0 || *p.MaxRetries > maxRetryLimit) {
+		return fmt.Errorf("%w: retry.maxRetries 必须在 0-%d 之间", errInvalidOptions, maxRetryLimit)
+	}
+	if p.Jitter != nil && (*p.Jitter < 0 || *p.Jitter > 1) {
+		return fmt.Errorf("%w: retry.jitter 必须在 0-1 之间", errInvalidOptions)
+	}
+	return nil
+}
+
+// retries 返回重试次数，不超过 maxRetryLimit
+func (p RetryPolicy) retries() int {
+	if p.MaxRetries == nil || *p.MaxRetries < 0 {
+		return defaultMaxRetries
+	}
+	return min(*p.MaxRetries, maxRetryLimit)
+}
+
+// delay 返回第 attempt 次尝试失败后的等待时间：指数退避，加上随机抖动
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+	jitter := defaultRetryJitter
+	if p.Jitter != nil && *p.Jitter >= 0 && *p.Jitter <= 1 {
+		jitter = *p.Jitter
+	}
+
+	delay := float64(base)
+	for i := 1; i < attempt && delay < float64(maxDelay); i++ {
+		delay *= 2
+	}
+	// 在 [1-jitter, 1+jitter] 范围内随机浮动，不超过上限
+	delay *= 1 - jitter + 2*jitter*rand.Float64()
+	delay = min(delay, float64(maxDelay))
+	return time.Duration(delay) * time.Millisecond
+}
+
+// timeoutStep 返回每次重试增加的超时时间
+func (p RetryPolicy) timeoutStep() time.Duration {
+	if p.TimeoutStep <= 0 {
+		return defaultRetryTimeoutStep * time.Second
+	}
+	return time.Duration(p.TimeoutStep) * time.Second
+}
+
+// shouldRetry 判断该类型的失败是否重试
+func (p RetryPolicy) shouldRetry(kind ErrorKind) bool {
+	if len(p.RetryOn) == 0 {
+		return kind.Retryable()
+	}
+	for _, k := range p.RetryOn {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// finalPassConcurrency 返回最终重试轮的并发数
+func (p RetryPolicy) finalPassConcurrency() int {
+	if p.FinalPassConcurrency <= 0 {
+		return defaultFinalPassConcurrency
+	}
+	return p.FinalPassConcurrency
+}
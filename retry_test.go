@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	noJitter := 0.0
+	exact := RetryPolicy{BaseDelay: 1000, MaxDelay: 5000, Jitter: &noJitter}
+	for attempt, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  5 * time.Second,
+		20: 5 * time.Second,
+	} {
+		if got := exact.delay(attempt); got != want {
+			t.Errorf("delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	// 默认抖动在 [0.7, 1.3] 倍之间，且不超过上限
+	jittered := RetryPolicy{}
+	for i := 0; i < 200; i++ {
+		if d := jittered.delay(1); d < 700*time.Millisecond || d > 1300*time.Millisecond {
+			t.Fatalf("delay(1) = %v, 超出默认抖动范围", d)
+		}
+		if d := jittered.delay(30); d > defaultRetryMaxDelay*time.Millisecond {
+			t.Fatalf("delay(30) = %v, 超过上限", d)
+		}
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		valid  bool
+	}{
+		{"默认值", RetryPolicy{}, true},
+		{"不重试", RetryPolicy{MaxRetries: intPtr(0)}, true},
+		{"上限", RetryPolicy{MaxRetries: intPtr(maxRetryLimit)}, true},
+		{"超过上限", RetryPolicy{MaxRetries: intPtr(1000000)}, false},
+		{"负数", RetryPolicy{MaxRetries: intPtr(-1)}, false},
+		{"关闭抖动", RetryPolicy{Jitter: floatPtr(0)}, true},
+		{"抖动超过1", RetryPolicy{Jitter: floatPtr(1.5)}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: validate() = %v, valid %v", tt.name, err, tt.valid)
+		}
+	}
+	if got := (RetryPolicy{MaxRetries: intPtr(1000000)}).retries(); got != maxRetryLimit {
+		t.Errorf("retries() = %d, want %d", got, maxRetryLimit)
+	}
+}
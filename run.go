@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runsDir 所有批量任务的输出根目录，每次批量截图在其下创建一个运行目录
+var runsDir = "runs"
+
+// Run 一次批量截图任务的运行目录，截图及PDF/MHTML等附件都保存在这里
+type Run struct {
+	ID        string
+	Dir       string
+	StartedAt time.Time
+
+	mu      sync.Mutex
+	results map[int]*CaptureResult // 按目标序号保存，最终重试轮重新截图的结果替换之前的失败结果
+}
+
+// 附件类型
+const (
+	artifactScreenshot = "screenshot"
+	artifactPDF        = "pdf"
+	artifactMHTML      = "mhtml"
+	artifactHAR        = "har"
+	artifactDOM        = "dom"
+	artifactText       = "text"
+	artifactFavicon    = "favicon"
+)
+
+var (
+	unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+	runIDPattern    = regexp.MustCompile(`^\d{8}-\d{6}(-\d+)?$`)
+)
+
+// newRun 创建新的运行目录，同一秒内创建多个运行（如连续的单个截图）时编号追加序号
+func newRun() (*Run, error) {
+	startedAt := time.Now()
+	if err := os.MkdirAll(runsDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建运行目录失败: %v", err)
+	}
+	base := startedAt.Format("20060102-150405")
+	id := base
+	for n := 2; ; n++ {
+		dir := filepath.Join(runsDir, id)
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return &Run{ID: id, Dir: dir, StartedAt: startedAt, results: make(map[int]*CaptureResult)}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("创建运行目录失败: %v", err)
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// artifactBaseName 根据序号和URL生成附件文件名（不含扩展名）
+func artifactBaseName(index int, rawURL string) string {
+	name := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		name = u.Host + u.Path
+	}
+	name = unsafeNameChars.ReplaceAllString(name, "_")
+	if len(name) > 60 {
+		name = name[:60]
+	}
+	return fmt.Sprintf("%03d_%s", index+1, name)
+}
+
+// imageExt 根据文件头判断截图格式（整页截图为JPEG，其余为PNG）
+func imageExt(data []byte) string {
+	if bytes.HasPrefix(data, []byte{0xff, 0xd8}) {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// saveResult 将截图结果的图片和附件写入运行目录，并在结果中记录相对路径
+func (r *Run) saveResult(index int, result *CaptureResult) {
+	result.ID = fmt.Sprintf("%s-%03d", r.ID, index)
+	base := artifactBaseName(index, result.URL)
+	files := map[string][]byte{}
+	names := map[string]string{}
+	if len(result.Image) > 0 {
+		names[artifactScreenshot] = base + imageExt(result.Image)
+		files[artifactScreenshot] = result.Image
+	}
+	if len(result.PDF) > 0 {
+		names[artifactPDF] = base + ".pdf"
+		files[artifactPDF] = result.PDF
+	}
+	if len(result.MHTML) > 0 {
+		names[artifactMHTML] = base + ".mhtml"
+		files[artifactMHTML] = result.MHTML
+	}
+	if len(result.HAR) > 0 {
+		names[artifactHAR] = base + ".har"
+		files[artifactHAR] = result.HAR
+	}
+	if len(result.DOM) > 0 {
+		// 以文本保存，避免目标网站的页面在界面的源下被浏览器渲染执行
+		names[artifactDOM] = base + ".html.txt"
+		files[artifactDOM] = result.DOM
+	}
+	if len(result.Text) > 0 {
+		names[artifactText] = base + ".txt"
+		files[artifactText] = result.Text
+	}
+	if len(result.Favicon) > 0 {
+		names[artifactFavicon] = base + "_favicon" + faviconExt(result.FaviconType, result.Favicon)
+		files[artifactFavicon] = result.Favicon
+	}
+
+	for kind, data := range files {
+		if err := os.WriteFile(filepath.Join(r.Dir, names[kind]), data, 0644); err != nil {
+			fmt.Printf("保存 %s 的%s失败: %v\n", result.URL, kind, err)
+			continue
+		}
+		if result.Artifacts == nil {
+			result.Artifacts = make(map[string]string)
+		}
+		result.Artifacts[kind] = names[kind]
+	}
+
+	r.mu.Lock()
+	r.results[index] = result
+	r.mu.Unlock()
+
+	// 加入全文检索索引，页面文本已保存时检索摘要从文件读取
+	textFile := ""
+	if name, ok := result.Artifacts[artifactText]; ok {
+		textFile = filepath.Join(r.Dir, name)
+	}
+	pageIndex.add(result, result.Text, textFile)
+}
+
+// inlineUnsafeExts 浏览器会作为文档渲染并执行脚本的文件类型，运行目录中此类文件（除运行报告外）只作为附件下载
+var inlineUnsafeExts = map[string]bool{
+	".html":  true,
+	".htm":   true,
+	".xhtml": true,
+	".xml":   true,
+	".mhtml": true,
+	".svg":   true, // favicon可能是带脚本的SVG，作为<img>显示不受影响
+}
+
+// setUntrustedHeaders 为来自目标网站的内容设置响应头：禁止类型嗅探，并以 CSP sandbox 在独立的源中打开，
+// 页面脚本无法读取界面中的CSRF令牌或调用接口
+func setUntrustedHeaders(w http.ResponseWriter, name string) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	if path.Base(name) != "report.html" && inlineUnsafeExts[strings.ToLower(path.Ext(name))] {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", path.Base(name)))
+	}
+}
+
+// runFilesHandler 提供运行目录中的截图、附件和报告
+func runFilesHandler() http.Handler {
+	files := http.StripPrefix("/runs/", http.FileServer(http.Dir(runsDir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setUntrustedHeaders(w, r.URL.Path)
+		files.ServeHTTP(w, r)
+	})
+}
+
+// artifactURL 返回运行目录中附件的HTTP访问路径
+func (r *Run) artifactURL(name string) string {
+	return "/runs/" + r.ID + "/" + name
+}
+
+// reportURL 返回运行报告的HTTP访问路径
+func (r *Run) reportURL() string {
+	return r.artifactURL("report.html")
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+	<meta charset="UTF-8">
+	<title>WebCut 截图报告 {{.ID}}</title>
+	<style>
+		body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', 'Roboto', sans-serif; padding: 20px; }
+		.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(300px, 1fr)); gap: 15px; }
+		.item { border: 1px solid #ddd; border-radius: 4px; padding: 10px; }
+		.item img { max-width: 100%; height: auto; }
+		.failed { display: flex; align-items: center; justify-content: center; height: 160px; background: #f5f5f5; color: #666; border-radius: 4px; }
+		.title { font-size: 14px; margin: 4px 0; }
+		.category { display: inline-block; font-size: 11px; color: white; background: #888; border-radius: 3px; padding: 1px 6px; }
+		.category-login { background: #d35400; }
+		.category-waf { background: #8e44ad; }
+		.category-error { background: #c0392b; }
+		.category-default-page, .category-directory-listing { background: #2980b9; }
+		.url { font-size: 12px; color: #666; word-break: break-all; }
+		.error { font-size: 12px; color: #721c24; }
+		.links a { font-size: 12px; margin-right: 8px; }
+		.tech { display: inline-block; font-size: 11px; background: #eaf2fb; color: #1f5f99; border-radius: 3px; padding: 1px 6px; margin: 0 4px 4px 0; }
+		.favicon { width: 16px; height: 16px; vertical-align: middle; margin-right: 6px; }
+		.hash { font-size: 12px; color: #666; }
+		.cert { font-size: 12px; color: #666; }
+		.anomaly { color: #721c24; margin-left: 6px; }
+		.events { font-size: 12px; }
+		.events pre { white-space: pre-wrap; word-break: break-all; background: #f7f7f7; padding: 4px; margin: 0 0 4px 0; }
+	</style>
+</head>
+<body>
+	<h1>WebCut 截图报告</h1>
+	<p>运行编号: {{.ID}}，开始时间: {{.StartedAt.Format "2006-01-02 15:04:05"}}，共 {{len .Results}} 个URL</p>
+	<div class="grid">
+	{{range .Results}}
+		<div class="item">
+			{{with index .Artifacts "screenshot"}}<a href="{{.}}"><img src="{{.}}" alt="截图"></a>{{else}}<div class="failed">截图失败{{if .ErrorKind}} · {{.ErrorKind.Label}}{{end}}</div>{{end}}
+			{{if .CategoryLabel}}<span class="category category-{{.Category}}">{{.CategoryLabel}}</span>{{end}}
+			{{if .Title}}<p class="title">{{with index .Artifacts "favicon"}}<img class="favicon" src="{{.}}" alt="">{{end}}{{.Title}}</p>{{end}}
+			<p class="url">{{.URL}}</p>
+			{{if .FaviconMMH3}}<p class="hash">favicon mmh3: {{.FaviconMMH3}} · md5: {{.FaviconMD5}}</p>{{end}}
+			{{with .Technologies}}<p class="techs">{{range .}}<span class="tech">{{.Label}}</span>{{end}}</p>{{end}}
+			{{if .Error}}<p class="error" title="{{.ErrorKind}}">{{.Error}}</p>{{else if .ErrorKind}}<p class="error">HTTP {{.StatusCode}}</p>{{end}}
+			{{with .Certificate}}
+			<p class="cert" title="主体: {{.Subject}}&#10;颁发者: {{.Issuer}}&#10;SHA256: {{.FingerprintSHA256}}">
+				证书: {{.Issuer}}，有效期至 {{.NotAfter.Format "2006-01-02"}}
+				{{range .Anomalies}}<span class="anomaly">{{.}}</span>{{end}}
+			</p>
+			{{end}}
+			{{if or .Console .Exceptions .FailedRequests}}
+			<details class="events">
+				<summary>控制台 {{len .Console}} · 异常 {{len .Exceptions}} · 失败请求 {{len .FailedRequests}}</summary>
+				{{range .Exceptions}}<pre>{{.Message}}{{if .URL}}
+{{.URL}}:{{.Line}}:{{.Column}}{{end}}{{if .Stack}}
+{{.Stack}}{{end}}</pre>{{end}}
+				{{range .FailedRequests}}<pre>{{.Error}} {{.URL}}</pre>{{end}}
+				{{range .Console}}<pre>[{{.Level}}] {{.Text}}</pre>{{end}}
+			</details>
+			{{end}}
+			<p class="links">
+				{{with index .Artifacts "pdf"}}<a href="{{.}}">PDF</a>{{end}}
+				{{with index .Artifacts "mhtml"}}<a href="{{.}}">MHTML</a>{{end}}
+				{{with index .Artifacts "har"}}<a href="{{.}}">HAR</a>{{end}}
+				{{with index .Artifacts "dom"}}<a href="{{.}}">DOM</a>{{end}}
+				{{with index .Artifacts "text"}}<a href="{{.}}">文本</a>{{end}}
+			</p>
+		</div>
+	{{end}}
+	</div>
+</body>
+</html>
+`))
+
+// writeReport 生成运行报告（按URL排序并链接各类附件）和results.json结果记录
+func (r *Run) writeReport() error {
+	r.mu.Lock()
+	results := make([]*CaptureResult, 0, len(r.results))
+	for _, result := range r.results {
+		results = append(results, result)
+	}
+	r.mu.Unlock()
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].URL != results[j].URL {
+			return results[i].URL < results[j].URL
+		}
+		return resultIDLess(results[i].ID, results[j].ID)
+	})
+
+	var buf bytes.Buffer
+	err := reportTemplate.Execute(&buf, map[string]interface{}{
+		"ID":        r.ID,
+		"StartedAt": r.StartedAt,
+		"Results":   results,
+	})
+	if err != nil {
+		return fmt.Errorf("生成报告失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, "report.html"), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	// 同时保存完整的结果记录，便于导出和后续分析
+	buf.Reset()
+	if err := writeResultsJSON(&buf, results); err != nil {
+		return fmt.Errorf("生成结果记录失败: %v", err)
+	}
+	return os.WriteFile(filepath.Join(r.Dir, "results.json"), buf.Bytes(), 0644)
+}
+
+// loadRunResults 读取已完成运行的 results.json 结果记录
+func loadRunResults(id string) ([]*CaptureResult, error) {
+	if !runIDPattern.MatchString(id) {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(runsDir, id, "results.json"))
+	if err != nil {
+		return nil, err
+	}
+	var results []*CaptureResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("解析运行 %s 的结果记录失败: %v", id, err)
+	}
+	return results, nil
+}
+
+// RunInfo 已完成运行的概要
+type RunInfo struct {
+	ID     string `json:"id"`
+	Report string `json:"report"`
+}
+
+// listRuns 列出已保存结果记录的运行，最新的在前
+func listRuns() []RunInfo {
+	entries, err := os.ReadDir(runsDir)
+	if err != nil {
+		return nil
+	}
+	var runs []RunInfo
+	for _, entry := range entries {
+		id := entry.Name()
+		if !entry.IsDir() || !runIDPattern.MatchString(id) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(runsDir, id, "results.json")); err != nil {
+			continue
+		}
+		runs = append(runs, RunInfo{ID: id, Report: "/runs/" + id + "/report.html"})
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	return runs
+}
+
+// resultRunID 从结果ID（运行编号-序号）中取出运行编号
+func resultRunID(resultID string) string {
+	if i := strings.LastIndex(resultID, "-"); i > 0 {
+		return resultID[:i]
+	}
+	return ""
+}
+
+// resultIndex 从结果ID中取出目标序号，无法解析时返回-1
+func resultIndex(resultID string) int {
+	if i := strings.LastIndex(resultID, "-"); i > 0 {
+		if index, err := strconv.Atoi(resultID[i+1:]); err == nil {
+			return index
+		}
+	}
+	return -1
+}
+
+// resultIDLess 按目标序号比较结果ID；序号补零只到三位，1000个目标以上按字符串比较会出错
+func resultIDLess(a, b string) bool {
+	if ia, ib := resultIndex(a), resultIndex(b); ia != ib {
+		return ia < ib
+	}
+	return a < b
+}
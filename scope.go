@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Scope 任务范围，由域名（含子域名）、IP地址和CIDR网段组成
+type Scope struct {
+	domains []string
+	ips     map[string]bool
+	nets    []*net.IPNet
+}
+
+// newScope 解析范围列表，支持 example.com、*.example.com、10.0.0.1、10.0.0.0/8，也接受完整URL
+func newScope(entries []string) *Scope {
+	scope := &Scope{ips: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "://") {
+			if u, err := url.Parse(entry); err == nil {
+				entry = u.Hostname()
+			}
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			scope.nets = append(scope.nets, ipNet)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			scope.ips[ip.String()] = true
+			continue
+		}
+		scope.domains = append(scope.domains, strings.TrimPrefix(strings.TrimPrefix(entry, "*."), "."))
+	}
+	return scope
+}
+
+// defaultScope 未指定范围时，以目标列表的上级域名作为范围（如 sso.szcu.edu.cn -> szcu.edu.cn），
+// 最多放宽到可注册域名，szcu.edu.cn、x.com.cn 不会变成 edu.cn、com.cn
+func defaultScope(targets []string) *Scope {
+	var entries []string
+	for _, target := range targets {
+		u, err := url.Parse(normalizeURL(strings.TrimSpace(target)))
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		entries = append(entries, parentDomain(strings.ToLower(u.Hostname())))
+	}
+	return newScope(entries)
+}
+
+// parentDomain 返回主机的上级域名，主机本身是可注册域名（或公共后缀、IP）时返回主机本身
+func parentDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil || registrable == host {
+		return host
+	}
+	_, parent, _ := strings.Cut(host, ".")
+	return parent
+}
+
+// Empty 范围为空时不做限制
+func (s *Scope) Empty() bool {
+	return s == nil || (len(s.domains) == 0 && len(s.ips) == 0 && len(s.nets) == 0)
+}
+
+// AllowsHost 判断主机名是否在范围内
+func (s *Scope) AllowsHost(host string) bool {
+	if s.Empty() {
+		return true
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if ip := net.ParseIP(host); ip != nil {
+		if s.ips[ip.String()] {
+			return true
+		}
+		for _, ipNet := range s.nets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, domain := range s.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsURL 判断URL的主机是否在范围内
+func (s *Scope) AllowsURL(rawURL string) bool {
+	u, err := url.Parse(normalizeURL(rawURL))
+	if err != nil {
+		return false
+	}
+	return s.AllowsHost(u.Hostname())
+}
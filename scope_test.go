@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestScopeAllowsHost(t *testing.T) {
+	scope := newScope([]string{"*.example.com", "https://portal.test.org/login", "10.0.0.1", "192.168.1.0/24"})
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"www.example.com", true},
+		{"a.b.EXAMPLE.com", true},
+		{"badexample.com", false},
+		{"example.com.evil.net", false},
+		{"portal.test.org", true},
+		{"other.test.org", false},
+		{"10.0.0.1", true},
+		{"10.0.0.2", false},
+		{"192.168.1.77", true},
+		{"[192.168.1.1]", true},
+		{"192.168.2.1", false},
+	}
+	for _, tt := range tests {
+		if got := scope.AllowsHost(tt.host); got != tt.want {
+			t.Errorf("AllowsHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+
+	if !newScope(nil).AllowsHost("anything.net") {
+		t.Error("空范围应不做限制")
+	}
+}
+
+func TestDefaultScope(t *testing.T) {
+	tests := []struct {
+		target  string
+		allowed []string
+		denied  []string
+	}{
+		{"https://www.szcu.edu.cn", []string{"sso.szcu.edu.cn", "szcu.edu.cn"}, []string{"other.edu.cn"}},
+		{"szcu.edu.cn", []string{"szcu.edu.cn", "mail.szcu.edu.cn"}, []string{"pku.edu.cn", "edu.cn"}},
+		{"http://x.com.cn/path", []string{"x.com.cn", "a.x.com.cn"}, []string{"y.com.cn"}},
+		{"a.b.example.com", []string{"b.example.com", "c.b.example.com"}, []string{"example.com", "c.example.com"}},
+		{"example.com", []string{"example.com", "www.example.com"}, []string{"example.org"}},
+		{"http://10.1.2.3:8080", []string{"10.1.2.3"}, []string{"10.1.2.4"}},
+	}
+	for _, tt := range tests {
+		scope := defaultScope([]string{tt.target})
+		for _, host := range tt.allowed {
+			if !scope.AllowsHost(host) {
+				t.Errorf("defaultScope(%q) 应包含 %s", tt.target, host)
+			}
+		}
+		for _, host := range tt.denied {
+			if scope.AllowsHost(host) {
+				t.Errorf("defaultScope(%q) 不应包含 %s", tt.target, host)
+			}
+		}
+	}
+}
@@ -0,0 +1,607 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchField 检索的字段
+type searchField uint8
+
+const (
+	fieldURL searchField = iota
+	fieldTitle
+	fieldHeader
+	fieldTech
+	fieldText
+	fieldCount
+	fieldAny searchField = 255 // 不限字段
+)
+
+// 字段名称（查询中的 field: 前缀）和命中时的得分权重
+var (
+	fieldNames   = [fieldCount]string{"url", "title", "header", "tech", "text"}
+	fieldWeights = [fieldCount]int{3, 3, 2, 2, 1}
+)
+
+const (
+	// 每个结果的页面文本最多索引的词数，避免超长页面占用过多内存
+	maxIndexedTextTokens = 20000
+	// 响应头之间的位置间隔，短语不会跨两个响应头匹配
+	headerPositionGap = 8
+	// 摘要中命中位置前后保留的字符数
+	snippetContext = 60
+)
+
+var (
+	// errEmptyQuery 检索内容为空
+	errEmptyQuery = errors.New("请输入检索内容")
+	// 摘要中连续的空白合并为一个空格
+	whitespaceRun = regexp.MustCompile(`\s+`)
+)
+
+// tokenSpan 分词结果及其在原文中的字节位置
+type tokenSpan struct {
+	term       string
+	start, end int
+}
+
+// isIdeograph 中日韩文字逐字作为一个词，检索多个字时按短语匹配
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// tokenizeSpans 分词：连续的字母数字为一个词（转为小写），中日韩文字每个字为一个词，其余字符为分隔符
+func tokenizeSpans(s string) []tokenSpan {
+	var spans []tokenSpan
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			spans = append(spans, tokenSpan{term: strings.ToLower(s[start:end]), start: start, end: end})
+			start = -1
+		}
+	}
+	for i, r := range s {
+		switch {
+		case isIdeograph(r):
+			flush(i)
+			size := utf8.RuneLen(r)
+			spans = append(spans, tokenSpan{term: s[i : i+size], start: i, end: i + size})
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(s))
+	return spans
+}
+
+// tokenize 返回分词后的词列表
+func tokenize(s string) []string {
+	spans := tokenizeSpans(s)
+	terms := make([]string, len(spans))
+	for i, span := range spans {
+		terms[i] = span.term
+	}
+	return terms
+}
+
+// posting 词在某个结果某个字段中出现的位置（升序）
+type posting struct {
+	doc       int
+	field     searchField
+	positions []int
+}
+
+// indexedDoc 已索引的截图结果，页面文本不常驻内存，生成摘要时从运行目录读取
+type indexedDoc struct {
+	ID         string
+	URL        string
+	Title      string
+	Headers    map[string]string
+	Techs      []string
+	CapturedAt time.Time
+	Screenshot bool
+	textFile   string
+	text       []byte // 当前任务的结果直接使用内存中的文本
+	replaced   bool   // 已被同一ID的新结果（最终重试轮重新截图）替换，检索时跳过
+}
+
+// searchIndex 所有运行截图结果的倒排索引：URL、标题、响应头、产品标签和页面可见文本
+type searchIndex struct {
+	mu    sync.RWMutex
+	docs  []indexedDoc
+	ids   map[string]int // 结果ID -> 当前文档序号
+	terms map[string][]posting
+	runs  map[string]bool // 已读取 results.json 的运行
+}
+
+var pageIndex = &searchIndex{
+	ids:   make(map[string]int),
+	terms: make(map[string][]posting),
+	runs:  make(map[string]bool),
+}
+
+// headerLines 按名称排序的响应头，格式为 "名称: 值"
+func headerLines(headers map[string]string) []string {
+	lines := make([]string, 0, len(headers))
+	for name, value := range headers {
+		lines = append(lines, name+": "+value)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// add 将截图结果加入索引，text 为页面可见文本，同一ID已索引时替换之前的记录
+func (idx *searchIndex) add(result *CaptureResult, text []byte, textFile string) {
+	if result.ID == "" {
+		return
+	}
+	fields := [fieldCount]map[string][]int{}
+	addTerms := func(field searchField, terms []string, offset int) int {
+		if fields[field] == nil {
+			fields[field] = make(map[string][]int)
+		}
+		for i, term := range terms {
+			fields[field][term] = append(fields[field][term], offset+i)
+		}
+		return offset + len(terms)
+	}
+	end := addTerms(fieldURL, tokenize(result.URL), 0)
+	if result.FinalURL != "" && result.FinalURL != result.URL {
+		addTerms(fieldURL, tokenize(result.FinalURL), end+headerPositionGap)
+	}
+	addTerms(fieldTitle, tokenize(result.Title), 0)
+	offset := 0
+	for _, line := range headerLines(result.Headers) {
+		offset = addTerms(fieldHeader, tokenize(line), offset) + headerPositionGap
+	}
+	techs := technologyLabels(result)
+	offset = 0
+	for _, tech := range techs {
+		offset = addTerms(fieldTech, tokenize(tech), offset) + headerPositionGap
+	}
+	textTerms := tokenize(string(text))
+	addTerms(fieldText, textTerms[:min(len(textTerms), maxIndexedTextTokens)], 0)
+
+	doc := indexedDoc{
+		ID:         result.ID,
+		URL:        result.URL,
+		Title:      result.Title,
+		Headers:    result.Headers,
+		Techs:      techs,
+		CapturedAt: result.CapturedAt,
+		Screenshot: result.Artifacts[artifactScreenshot] != "",
+		textFile:   textFile,
+	}
+	if textFile == "" {
+		doc.text = text
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if old, ok := idx.ids[result.ID]; ok {
+		idx.docs[old].replaced = true
+	}
+	n := len(idx.docs)
+	idx.ids[result.ID] = n
+	idx.docs = append(idx.docs, doc)
+	for field, terms := range fields {
+		for term, positions := range terms {
+			idx.terms[term] = append(idx.terms[term], posting{doc: n, field: searchField(field), positions: positions})
+		}
+	}
+}
+
+// indexed 结果是否已在索引中
+func (idx *searchIndex) indexed(id string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	_, ok := idx.ids[id]
+	return ok
+}
+
+// indexRuns 将尚未索引的已完成运行加入索引，运行中的任务没有 results.json，下次检索时再读取
+func (idx *searchIndex) indexRuns() {
+	entries, err := os.ReadDir(runsDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id := entry.Name()
+		idx.mu.RLock()
+		done := idx.runs[id]
+		idx.mu.RUnlock()
+		if done || !entry.IsDir() || !runIDPattern.MatchString(id) {
+			continue
+		}
+		results, err := loadRunResults(id)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				fmt.Printf("检索索引读取运行 %s 失败: %v\n", id, err)
+			}
+			continue
+		}
+		added := 0
+		for _, result := range results {
+			if idx.indexed(result.ID) {
+				continue
+			}
+			var text []byte
+			textFile := ""
+			if name, ok := result.Artifacts[artifactText]; ok {
+				textFile = filepath.Join(runsDir, id, name)
+				text, _ = os.ReadFile(textFile)
+			}
+			idx.add(result, text, textFile)
+			added++
+		}
+		idx.mu.Lock()
+		idx.runs[id] = true
+		idx.mu.Unlock()
+		if added > 0 {
+			fmt.Printf("检索索引已加入运行 %s 的 %d 个结果\n", id, added)
+		}
+	}
+}
+
+// searchClause 查询中的一个条件：在指定字段中按顺序出现的词，negate 为排除条件
+type searchClause struct {
+	field  searchField
+	phrase []string
+	negate bool
+}
+
+// appliesTo 条件是否检索该字段
+func (c searchClause) appliesTo(field searchField) bool {
+	return c.field == fieldAny || c.field == field
+}
+
+// parseQuery 解析查询：空格分隔的条件同时满足，"..." 为短语，-前缀为排除，
+// url:、title:、header:、tech:、text: 限定字段，其他 名称: 前缀按响应头名称检索（如 server:apache-coyote）
+func parseQuery(query string) ([]searchClause, error) {
+	var clauses []searchClause
+	positive := false
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		var clause searchClause
+		clause.field = fieldAny
+		if strings.HasPrefix(rest, "-") {
+			clause.negate = true
+			rest = rest[1:]
+		}
+
+		// 字段前缀：字母开头、只含字母数字和-_、后跟冒号，排除 http:// 这类网址
+		prefix := ""
+		if i := strings.IndexByte(rest, ':'); i > 0 && !strings.HasPrefix(rest[i+1:], "/") {
+			if name := rest[:i]; unicode.IsLetter(rune(name[0])) && strings.IndexFunc(name, notFieldNameRune) < 0 {
+				prefix, rest = strings.ToLower(name), rest[i+1:]
+			}
+		}
+
+		var value string
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		rest = strings.TrimSpace(rest)
+
+		clause.phrase = tokenize(value)
+		if prefix != "" {
+			clause.field = fieldHeader
+			for field, name := range fieldNames {
+				if name == prefix {
+					clause.field = searchField(field)
+				}
+			}
+			// 响应头名称作为短语的开头，匹配 "名称: 值"
+			if clause.field == fieldHeader && prefix != "header" {
+				clause.phrase = append(tokenize(prefix), clause.phrase...)
+			}
+		}
+		if len(clause.phrase) == 0 {
+			continue
+		}
+		positive = positive || !clause.negate
+		clauses = append(clauses, clause)
+	}
+	if !positive {
+		return nil, errEmptyQuery
+	}
+	return clauses, nil
+}
+
+// notFieldNameRune 不能出现在字段前缀中的字符
+func notFieldNameRune(r rune) bool {
+	return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_')
+}
+
+// phraseAt 位置 p 开始是否依次出现短语中的其余词，positions 为其余各词在同一字段中的位置
+func phraseAt(p int, positions [][]int) bool {
+	for i, list := range positions {
+		want := p + i + 1
+		j := sort.SearchInts(list, want)
+		if j >= len(list) || list[j] != want {
+			return false
+		}
+	}
+	return true
+}
+
+// matchClause 返回满足条件的结果及得分（出现次数乘以字段权重），以及命中的字段，调用方需持有读锁
+func (idx *searchIndex) matchClause(c searchClause) (map[int]int, map[int][]searchField) {
+	type key struct {
+		doc   int
+		field searchField
+	}
+	// 短语中其余词在各结果各字段中的位置
+	rest := make([]map[key][]int, len(c.phrase)-1)
+	for i, term := range c.phrase[1:] {
+		rest[i] = make(map[key][]int)
+		for _, p := range idx.terms[term] {
+			rest[i][key{p.doc, p.field}] = p.positions
+		}
+	}
+
+	scores := make(map[int]int)
+	fields := make(map[int][]searchField)
+	for _, p := range idx.terms[c.phrase[0]] {
+		if !c.appliesTo(p.field) {
+			continue
+		}
+		k := key{p.doc, p.field}
+		positions := make([][]int, len(rest))
+		missing := false
+		for i := range rest {
+			if positions[i] = rest[i][k]; positions[i] == nil {
+				missing = true
+				break
+			}
+		}
+		if missing {
+			continue
+		}
+		count := 0
+		for _, pos := range p.positions {
+			if phraseAt(pos, positions) {
+				count++
+			}
+		}
+		if count > 0 {
+			scores[p.doc] += count * fieldWeights[p.field]
+			fields[p.doc] = append(fields[p.doc], p.field)
+		}
+	}
+	return scores, fields
+}
+
+// SnippetPart 摘要片段，Match 为命中的部分
+type SnippetPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// SearchHit 检索命中的截图结果
+type SearchHit struct {
+	ID         string        `json:"id"`
+	RunID      string        `json:"runId"`
+	URL        string        `json:"url"`
+	Title      string        `json:"title,omitempty"`
+	Image      string        `json:"image,omitempty"`
+	Thumb      string        `json:"thumb,omitempty"`
+	CapturedAt time.Time     `json:"capturedAt"`
+	Fields     []string      `json:"fields"`
+	Score      int           `json:"score"`
+	Snippet    []SnippetPart `json:"snippet,omitempty"`
+
+	doc     int
+	clauses []searchClause
+}
+
+// search 检索所有运行（runs 不为空时只检索这些运行），按得分和截图时间排序
+func (idx *searchIndex) search(query string, runs map[string]bool) ([]SearchHit, error) {
+	clauses, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	idx.indexRuns()
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var scores map[int]int
+	matched := make(map[int]map[searchField]bool)
+	excluded := make(map[int]bool)
+	for _, c := range clauses {
+		clauseScores, clauseFields := idx.matchClause(c)
+		if c.negate {
+			for doc := range clauseScores {
+				excluded[doc] = true
+			}
+			continue
+		}
+		if scores == nil {
+			scores = clauseScores
+		} else {
+			for doc, score := range scores {
+				if s, ok := clauseScores[doc]; ok {
+					scores[doc] = score + s
+				} else {
+					delete(scores, doc)
+				}
+			}
+		}
+		for doc, fields := range clauseFields {
+			if matched[doc] == nil {
+				matched[doc] = make(map[searchField]bool)
+			}
+			for _, field := range fields {
+				matched[doc][field] = true
+			}
+		}
+	}
+
+	hits := make([]SearchHit, 0, len(scores))
+	for n, score := range scores {
+		doc := idx.docs[n]
+		runID := resultRunID(doc.ID)
+		if doc.replaced || excluded[n] || (len(runs) > 0 && !runs[runID]) {
+			continue
+		}
+		hit := SearchHit{
+			ID:         doc.ID,
+			RunID:      runID,
+			URL:        doc.URL,
+			Title:      doc.Title,
+			CapturedAt: doc.CapturedAt,
+			Score:      score,
+			doc:        n,
+			clauses:    clauses,
+		}
+		if doc.Screenshot {
+			hit.Image = "/api/images/" + doc.ID
+			hit.Thumb = "/thumb/" + doc.ID
+		}
+		for field := searchField(0); field < fieldCount; field++ {
+			if matched[n][field] {
+				hit.Fields = append(hit.Fields, fieldNames[field])
+			}
+		}
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if !hits[i].CapturedAt.Equal(hits[j].CapturedAt) {
+			return hits[i].CapturedAt.After(hits[j].CapturedAt)
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits, nil
+}
+
+// withSnippet 为命中结果生成摘要：依次在页面文本、响应头、产品标签、标题和网址中找到第一个命中的位置，
+// 截取前后的文字并标出所有命中的词
+func (idx *searchIndex) withSnippet(hit SearchHit) SearchHit {
+	idx.mu.RLock()
+	doc := idx.docs[hit.doc]
+	idx.mu.RUnlock()
+
+	text := doc.text
+	if doc.textFile != "" {
+		text, _ = os.ReadFile(doc.textFile)
+	}
+	sources := []struct {
+		field searchField
+		texts []string
+	}{
+		{fieldText, []string{string(text)}},
+		{fieldHeader, headerLines(doc.Headers)},
+		{fieldTech, doc.Techs},
+		{fieldTitle, []string{doc.Title}},
+		{fieldURL, []string{doc.URL}},
+	}
+	for _, source := range sources {
+		for _, s := range source.texts {
+			if parts := snippetOf(s, source.field, hit.clauses); parts != nil {
+				hit.Snippet = parts
+				return hit
+			}
+		}
+	}
+	return hit
+}
+
+// snippetOf 在文本中查找条件的命中位置，没有命中时返回nil
+func snippetOf(s string, field searchField, clauses []searchClause) []SnippetPart {
+	spans := tokenizeSpans(s)
+	if field == fieldText && len(spans) > maxIndexedTextTokens {
+		spans = spans[:maxIndexedTextTokens]
+	}
+	type match struct{ start, end int }
+	var matches []match
+	for _, c := range clauses {
+		if c.negate || !c.appliesTo(field) {
+			continue
+		}
+		for i := 0; i+len(c.phrase) <= len(spans); i++ {
+			found := true
+			for j, term := range c.phrase {
+				if spans[i+j].term != term {
+					found = false
+					break
+				}
+			}
+			if found {
+				matches = append(matches, match{spans[i].start, spans[i+len(c.phrase)-1].end})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	// 以第一个命中为中心截取，前后各保留 snippetContext 个字符
+	from, to := matches[0].start, matches[0].end
+	for n := 0; n < snippetContext && from > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(s[:from])
+		from -= size
+	}
+	for n := 0; n < snippetContext && to < len(s); n++ {
+		_, size := utf8.DecodeRuneInString(s[to:])
+		to += size
+	}
+
+	var parts []SnippetPart
+	addPart := func(text string, isMatch bool) {
+		if text = whitespaceRun.ReplaceAllString(text, " "); text != "" {
+			parts = append(parts, SnippetPart{Text: text, Match: isMatch})
+		}
+	}
+	pos := from
+	for _, m := range matches {
+		if m.start < pos || m.end > to {
+			continue
+		}
+		addPart(s[pos:m.start], false)
+		addPart(s[m.start:m.end], true)
+		pos = m.end
+	}
+	addPart(s[pos:to], false)
+	if first := &parts[0]; !first.Match {
+		first.Text = strings.TrimLeft(first.Text, " ")
+	}
+	if last := &parts[len(parts)-1]; !last.Match {
+		last.Text = strings.TrimRight(last.Text, " ")
+	}
+	if from > 0 {
+		parts = append([]SnippetPart{{Text: "…"}}, parts...)
+	}
+	if to < len(s) {
+		parts = append(parts, SnippetPart{Text: "…"})
+	}
+	return parts
+}
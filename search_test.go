@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []searchClause
+		err   error
+	}{
+		{"nginx", []searchClause{{field: fieldAny, phrase: []string{"nginx"}}}, nil},
+		{"  Apache  Tomcat ", []searchClause{
+			{field: fieldAny, phrase: []string{"apache"}},
+			{field: fieldAny, phrase: []string{"tomcat"}},
+		}, nil},
+		{`"Welcome to nginx"`, []searchClause{{field: fieldAny, phrase: []string{"welcome", "to", "nginx"}}}, nil},
+		{`"unterminated phrase`, []searchClause{{field: fieldAny, phrase: []string{"unterminated", "phrase"}}}, nil},
+		{"login -test", []searchClause{
+			{field: fieldAny, phrase: []string{"login"}},
+			{field: fieldAny, phrase: []string{"test"}, negate: true},
+		}, nil},
+		{`title:"Sign In" tech:WordPress`, []searchClause{
+			{field: fieldTitle, phrase: []string{"sign", "in"}},
+			{field: fieldTech, phrase: []string{"wordpress"}},
+		}, nil},
+		{"URL:admin -text:404", []searchClause{
+			{field: fieldURL, phrase: []string{"admin"}},
+			{field: fieldText, phrase: []string{"404"}, negate: true},
+		}, nil},
+		{"header:nginx", []searchClause{{field: fieldHeader, phrase: []string{"nginx"}}}, nil},
+		{"server:apache-coyote", []searchClause{{field: fieldHeader, phrase: []string{"server", "apache", "coyote"}}}, nil},
+		{"X-Powered-By:PHP", []searchClause{{field: fieldHeader, phrase: []string{"x", "powered", "by", "php"}}}, nil},
+		{"http://example.com/login", []searchClause{{field: fieldAny, phrase: []string{"http", "example", "com", "login"}}}, nil},
+		{"统一身份认证", []searchClause{{field: fieldAny, phrase: []string{"统", "一", "身", "份", "认", "证"}}}, nil},
+		{"title:后台管理", []searchClause{{field: fieldTitle, phrase: []string{"后", "台", "管", "理"}}}, nil},
+		{"nginx title:", []searchClause{{field: fieldAny, phrase: []string{"nginx"}}}, nil},
+		{"", nil, errEmptyQuery},
+		{"   ", nil, errEmptyQuery},
+		{`"" ... !!`, nil, errEmptyQuery},
+		{"-nginx -title:test", nil, errEmptyQuery},
+	}
+	for _, tt := range tests {
+		got, err := parseQuery(tt.query)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("parseQuery(%q) error = %v, want %v", tt.query, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
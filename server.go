@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// 桌面模式优先使用的监听地址，端口被占用时改用随机端口
+const defaultListenAddr = "127.0.0.1:1427"
+
+// ServerConfig HTTP服务器配置
+type ServerConfig struct {
+	Listen         string            // 监听地址，端口为0时随机选择空闲端口
+	FallbackRandom bool              // 监听地址被占用时改用同一主机的随机端口
+	TLSCert        string            // TLS证书文件，与 TLSKey 同时设置时启用HTTPS
+	TLSKey         string            // TLS私钥文件
+	Token          string            // 共享访问令牌
+	APIKeys        map[string]string // 每个用户的API密钥，密钥 -> 用户名
+	AllowedOrigins []string          // 允许跨域调用的来源，如 https://dashboard.example.com
+}
+
+// tls 是否启用HTTPS
+func (c ServerConfig) tls() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// validate 检查配置：证书和私钥需同时设置，监听非本机地址时必须启用认证
+func (c ServerConfig) validate() error {
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("-tls-cert 和 -tls-key 需要同时设置")
+	}
+	if c.Token == "" && len(c.APIKeys) == 0 && !isLoopbackListen(c.Listen) {
+		return fmt.Errorf("监听非本机地址 %s 时必须设置 -token 或 -api-keys", c.Listen)
+	}
+	return nil
+}
+
+// isLoopbackListen 判断监听地址是否只在本机可访问
+func isLoopbackListen(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listen 按配置监听，地址被占用且允许回退时改用随机端口
+func (c ServerConfig) listen() (net.Listener, error) {
+	listener, err := net.Listen("tcp", c.Listen)
+	if err != nil && c.FallbackRandom {
+		host, _, splitErr := net.SplitHostPort(c.Listen)
+		if splitErr != nil {
+			return nil, err
+		}
+		fmt.Printf("监听 %s 失败（%v），改用随机端口\n", c.Listen, err)
+		listener, err = net.Listen("tcp", net.JoinHostPort(host, "0"))
+	}
+	return listener, err
+}
+
+// baseURL 返回服务器的访问地址，监听所有地址时使用本机地址
+func (c ServerConfig) baseURL(addr net.Addr) string {
+	host, port, _ := net.SplitHostPort(addr.String())
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	scheme := "http"
+	if c.tls() {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// runServe serve 子命令：不打开窗口，作为共享的截图服务器运行，直到收到中断信号
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", defaultListenAddr, "监听地址，如 0.0.0.0:1427；端口为0时随机选择空闲端口")
+	tlsCert := flags.String("tls-cert", "", "TLS证书文件")
+	tlsKey := flags.String("tls-key", "", "TLS私钥文件")
+	token := flags.String("token", os.Getenv("WEBCUT_TOKEN"), "共享访问令牌，也可通过环境变量 WEBCUT_TOKEN 设置")
+	apiKeys := flags.String("api-keys", "", "API密钥文件，每行一个 用户名:密钥")
+	origins := flags.String("allow-origin", "", "允许跨域调用的来源，多个用逗号分隔")
+	runs := flags.String("runs", runsDir, "运行结果保存目录")
+	flags.Parse(args)
+
+	cfg := ServerConfig{
+		Listen:  *listen,
+		TLSCert: *tlsCert,
+		TLSKey:  *tlsKey,
+		Token:   *token,
+	}
+	if *apiKeys != "" {
+		keys, err := loadAPIKeys(*apiKeys)
+		if err != nil {
+			return fmt.Errorf("读取API密钥文件失败: %v", err)
+		}
+		cfg.APIKeys = keys
+		fmt.Printf("已加载 %d 个API密钥\n", len(keys))
+	}
+	for _, origin := range strings.Split(*origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	runsDir = *runs
+
+	server, addr, err := startServer(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("截图服务器已启动: %s\n", addr)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+	fmt.Println("正在关闭服务器...")
+	server.Close()
+	return nil
+}
+
+// serveHTTP 在监听器上启动服务器，启用TLS时使用HTTPS
+func serveHTTP(server *http.Server, listener net.Listener) {
+	var err error
+	if server.TLSConfig != nil {
+		err = server.ServeTLS(listener, "", "")
+	} else {
+		err = server.Serve(listener)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("服务器异常退出: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newHTTPServer 创建带访问控制的HTTP服务器，处理 handler 上注册的接口；启用TLS时先加载证书
+func newHTTPServer(cfg ServerConfig, handler http.Handler) (*http.Server, error) {
+	server := &http.Server{
+		Handler:           newGuard(cfg).wrap(handler),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if cfg.tls() {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("加载TLS证书失败: %v", err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	return server, nil
+}
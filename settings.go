@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const (
+	// 批量截图默认并发数，浏览器池大小为10，实际运行时保留一些缓冲
+	defaultConcurrency = 5
+	maxConcurrency     = 10
+)
+
+// Settings 服务端设置，可通过 /api/v1/settings 查看和修改
+type Settings struct {
+	Concurrency int            `json:"concurrency"` // 批量截图并发数
+	Options     CaptureOptions `json:"options"`     // 通过API提交任务且未指定截图选项时使用的默认选项
+}
+
+var (
+	settings      = Settings{Concurrency: defaultConcurrency}
+	settingsMutex sync.Mutex
+)
+
+// currentSettings 返回当前设置
+func currentSettings() Settings {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	return settings
+}
+
+// updateSettings 校验并替换设置，并发数为0时使用默认值
+func updateSettings(s Settings) (Settings, error) {
+	if s.Concurrency == 0 {
+		s.Concurrency = defaultConcurrency
+	}
+	if s.Concurrency < 1 || s.Concurrency > maxConcurrency {
+		return Settings{}, fmt.Errorf("concurrency 必须在 1-%d 之间", maxConcurrency)
+	}
+	if err := s.Options.Retry.validate(); err != nil {
+		return Settings{}, err
+	}
+	settingsMutex.Lock()
+	settings = s
+	settingsMutex.Unlock()
+	return s, nil
+}
+
+// clone 返回设置的深拷贝，修改副本（包括解析请求体）不会影响共享的设置
+func (s Settings) clone() Settings {
+	var copied Settings
+	data, err := json.Marshal(s)
+	if err == nil {
+		err = json.Unmarshal(data, &copied)
+	}
+	if err != nil {
+		return Settings{Concurrency: s.Concurrency}
+	}
+	return copied
+}
+
+// redacted 返回隐藏了默认选项中认证信息的设置，用于接口返回
+func (s Settings) redacted() Settings {
+	s.Options.Auth = s.Options.Auth.redacted()
+	return s
+}
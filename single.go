@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errNoURL 单个截图请求没有提供URL
+var errNoURL = errors.New("请输入要截图的网址")
+
+// SingleCapture 单个URL截图的结果，与批量任务一样保存为一次运行
+type SingleCapture struct {
+	RunID  string         `json:"runId"`
+	Report string         `json:"report"`
+	Result *CaptureResult `json:"result"`
+}
+
+// captureSingle 截图单个URL并将结果写入新的运行目录，截图失败时同样保存失败结果并返回错误
+// 可与批量任务同时进行，共用浏览器池；证书SAN发现和任务范围只对批量任务生效
+func captureSingle(url string, opts CaptureOptions) (*SingleCapture, error) {
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return nil, errNoURL
+	}
+	if err := opts.Retry.validate(); err != nil {
+		return nil, err
+	}
+	run, err := newRun()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("准备截图URL: %s（保存到 %s）\n", url, run.Dir)
+
+	result, err := captureScreenshot(url, opts)
+	run.saveResult(0, result)
+	if err == nil {
+		result.ImageURL = "/api/images/" + result.ID
+		result.ThumbURL = "/thumb/" + result.ID
+		fmt.Printf("URL %s 截图成功\n", url)
+	} else {
+		fmt.Printf("URL %s 截图失败: %v\n", url, err)
+	}
+	if reportErr := run.writeReport(); reportErr != nil {
+		fmt.Printf("生成运行报告失败: %v\n", reportErr)
+	}
+	return &SingleCapture{RunID: run.ID, Report: run.reportURL(), Result: result}, err
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"time"
+)
+
+// 错误率突增提醒：最近 errorSpikeWindow 个结果中失败比例达到 errorSpikeRate 时通知，
+// 降到一半以下后可再次通知
+const (
+	errorSpikeWindow = 20
+	errorSpikeRate   = 0.5
+)
+
+// trayStatus 托盘菜单和提示中显示的任务状态
+type trayStatus struct {
+	Running bool
+	Paused  bool
+	Text    string
+}
+
+// jobMonitor 在后台跟随最新的批量任务（无论由哪个界面或API提交），
+// 为托盘提供进度，并在任务结束或错误率突增时发出桌面通知
+type jobMonitor struct {
+	update func(trayStatus)
+	notify func(title, message string)
+}
+
+// run 持续检查是否有新任务，依次跟随
+func (m *jobMonitor) run() {
+	m.update(trayStatus{Text: "没有运行中的任务"})
+	var followed string
+	for {
+		if jobs := listJobs(); len(jobs) > 0 && jobs[0].ID != followed {
+			followed = jobs[0].ID
+			if job := findJob(followed); job != nil {
+				m.follow(job)
+			}
+			continue
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// follow 跟随任务直到结束
+func (m *jobMonitor) follow(job *Job) {
+	lastID := 0
+	var recent []bool
+	spiking := false
+	for {
+		events, notify, finished := job.eventsSince(lastID)
+		for _, event := range events {
+			lastID = event.ID
+			switch event.Type {
+			case EventResult, EventError:
+				if _, ok := event.Data["completedUrl"]; !ok {
+					continue
+				}
+				failed, _ := event.Data["failed"].(bool)
+				recent = append(recent, failed)
+				if len(recent) > errorSpikeWindow {
+					recent = recent[1:]
+				}
+				rate := failureRate(recent)
+				if !spiking && len(recent) == errorSpikeWindow && rate >= errorSpikeRate {
+					spiking = true
+					m.notify("截图失败率升高", fmt.Sprintf("最近 %d 个URL中 %.0f%% 截图失败，请检查网络或目标状态", len(recent), rate*100))
+				} else if spiking && rate < errorSpikeRate/2 {
+					spiking = false
+				}
+			case EventCompleted:
+				title := "批量截图完成"
+				if canceled, _ := event.Data["canceled"].(bool); canceled {
+					title = "批量截图已取消"
+				}
+				status, _ := event.Data["status"].(string)
+				failedCount, _ := event.Data["failedCount"].(int)
+				m.notify(title, fmt.Sprintf("%s，%d 个失败", status, failedCount))
+			}
+		}
+
+		snapshot := job.snapshot()
+		m.update(statusOf(snapshot))
+		if finished {
+			return
+		}
+		<-notify
+	}
+}
+
+// failureRate 计算失败比例
+func failureRate(recent []bool) float64 {
+	if len(recent) == 0 {
+		return 0
+	}
+	failed := 0
+	for _, f := range recent {
+		if f {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(recent))
+}
+
+// statusOf 根据任务状态生成托盘显示的文字
+func statusOf(job Job) trayStatus {
+	status := trayStatus{Running: job.Status == JobRunning, Paused: job.Paused}
+	switch {
+	case job.Status == JobCanceled:
+		status.Text = fmt.Sprintf("任务已取消：%d/%d，失败 %d", job.Completed, job.Total, job.Failed)
+	case job.Status != JobRunning:
+		status.Text = fmt.Sprintf("任务已完成：%d 个URL，失败 %d", job.Total, job.Failed)
+	case job.Paused:
+		status.Text = fmt.Sprintf("已暂停：%d/%d，失败 %d", job.Completed, job.Total, job.Failed)
+	default:
+		status.Text = fmt.Sprintf("正在截图：%d/%d，失败 %d", job.Completed, job.Total, job.Failed)
+	}
+	return status
+}
+
+// toggleCurrentJobPause 暂停或继续正在运行的任务
+func toggleCurrentJobPause() error {
+	job := currentJob()
+	if job == nil {
+		return errJobFinished
+	}
+	action := ActionPause
+	if job.snapshot().Paused {
+		action = ActionResume
+	}
+	return job.control(JobCommand{Action: action}, "")
+}
+
+// cancelCurrentJob 取消正在运行的任务
+func cancelCurrentJob() error {
+	job := currentJob()
+	if job == nil {
+		return errJobFinished
+	}
+	return job.control(JobCommand{Action: ActionCancelJob}, "")
+}
+
+// trayIconPNG 生成托盘图标：蓝色圆角方块中的白色取景框
+func trayIconPNG() []byte {
+	const size = 32
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	blue := color.RGBA{0x34, 0x98, 0xdb, 0xff}
+	white := color.RGBA{0xff, 0xff, 0xff, 0xff}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			// 去掉四角形成圆角
+			dx, dy := min(x, size-1-x), min(y, size-1-y)
+			if dx+dy < 3 {
+				continue
+			}
+			img.Set(x, y, blue)
+			// 取景框的四个角：框内靠近边缘2像素、且靠近角落的L形
+			inFrame := x >= 7 && x <= 24 && y >= 7 && y <= 24
+			onEdge := x <= 8 || x >= 23 || y <= 8 || y >= 23
+			nearCorner := (x <= 12 || x >= 19) && (y <= 12 || y >= 19)
+			if inFrame && onEdge && nearCorner {
+				img.Set(x, y, white)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// trayIconICO 将托盘图标封装为ICO格式（Windows托盘需要），ICO中直接嵌入PNG数据
+func trayIconICO() []byte {
+	data := trayIconPNG()
+	var buf bytes.Buffer
+	// ICONDIR：保留字段、类型（1为图标）、图像数量
+	binary.Write(&buf, binary.LittleEndian, []uint16{0, 1, 1})
+	// ICONDIRENTRY：宽、高、调色板数、保留、颜色平面、位深、数据大小、数据偏移
+	buf.Write([]byte{32, 32, 0, 0})
+	binary.Write(&buf, binary.LittleEndian, []uint16{1, 32})
+	binary.Write(&buf, binary.LittleEndian, []uint32{uint32(len(data)), 6 + 16})
+	buf.Write(data)
+	return buf.Bytes()
+}
@@ -0,0 +1,128 @@
+//go:build windows && !fyne
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"fyne.io/systray"
+	"github.com/jchv/go-webview2"
+)
+
+var (
+	user32              = syscall.NewLazyDLL("user32.dll")
+	procShowWindow      = user32.NewProc("ShowWindow")
+	procIsIconic        = user32.NewProc("IsIconic")
+	procIsWindowVisible = user32.NewProc("IsWindowVisible")
+	procSetForeground   = user32.NewProc("SetForegroundWindow")
+)
+
+// ShowWindow 的显示方式
+const (
+	swHide    = 0
+	swRestore = 9
+)
+
+// setupTray 创建托盘图标和菜单（点击图标打开）：显示任务进度，可暂停/取消任务，最小化窗口时隐藏到托盘
+// 托盘的消息由WebView的消息循环处理，需在主线程、w.Run() 之前调用
+func setupTray(w webview2.WebView) {
+	hwnd := uintptr(w.Window())
+	hideWindow := func() {
+		w.Dispatch(func() { procShowWindow.Call(hwnd, swHide) })
+	}
+	showWindow := func() {
+		w.Dispatch(func() {
+			procShowWindow.Call(hwnd, swRestore)
+			procSetForeground.Call(hwnd)
+		})
+	}
+
+	systray.Register(func() {
+		systray.SetIcon(trayIconICO())
+		systray.SetTooltip("WebCut-网页快照")
+		status := systray.AddMenuItem("没有运行中的任务", "")
+		status.Disable()
+		pause := systray.AddMenuItem("暂停", "暂停开始新的截图，执行中的目标继续完成")
+		cancel := systray.AddMenuItem("取消任务", "取消正在运行的批量任务")
+		systray.AddSeparator()
+		show := systray.AddMenuItem("显示窗口", "")
+		hide := systray.AddMenuItem("隐藏到托盘", "")
+		systray.AddSeparator()
+		quit := systray.AddMenuItem("退出", "")
+
+		monitor := &jobMonitor{
+			update: func(s trayStatus) {
+				status.SetTitle(s.Text)
+				systray.SetTooltip("WebCut-网页快照\n" + s.Text)
+				if s.Paused {
+					pause.SetTitle("继续")
+				} else {
+					pause.SetTitle("暂停")
+				}
+				if s.Running {
+					pause.Enable()
+					cancel.Enable()
+				} else {
+					pause.Disable()
+					cancel.Disable()
+				}
+			},
+			notify: showNotification,
+		}
+		go monitor.run()
+
+		// 窗口最小化时隐藏到托盘，通过托盘图标或菜单恢复
+		go func() {
+			for range time.Tick(500 * time.Millisecond) {
+				iconic, _, _ := procIsIconic.Call(hwnd)
+				visible, _, _ := procIsWindowVisible.Call(hwnd)
+				if iconic != 0 && visible != 0 {
+					hideWindow()
+				}
+			}
+		}()
+
+		for {
+			select {
+			case <-pause.ClickedCh:
+				if err := toggleCurrentJobPause(); err != nil {
+					fmt.Printf("暂停/继续任务失败: %v\n", err)
+				}
+			case <-cancel.ClickedCh:
+				if err := cancelCurrentJob(); err != nil {
+					fmt.Printf("取消任务失败: %v\n", err)
+				}
+			case <-show.ClickedCh:
+				showWindow()
+			case <-hide.ClickedCh:
+				hideWindow()
+			case <-quit.ClickedCh:
+				systray.Quit()
+				w.Dispatch(w.Terminate)
+				return
+			}
+		}
+	}, nil)
+}
+
+// 通过PowerShell调用Windows通知接口显示通知，标题和内容经环境变量传入，避免转义问题
+const toastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:WEBCUT_TITLE)) | Out-Null
+$text.Item(1).AppendChild($template.CreateTextNode($env:WEBCUT_MESSAGE)) | Out-Null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('WebCut').Show([Windows.UI.Notifications.ToastNotification]::new($template))`
+
+// showNotification 显示桌面通知
+func showNotification(title, message string) {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+	cmd.Env = append(os.Environ(), "WEBCUT_TITLE="+title, "WEBCUT_MESSAGE="+message)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("显示通知失败（%s: %s）: %v\n", title, message, err)
+	}
+}
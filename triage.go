@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 结果的审阅状态
+const (
+	TriageUnreviewed     = "unreviewed"      // 未审阅
+	TriageInteresting    = "interesting"     // 有价值
+	TriageNotInteresting = "not-interesting" // 无价值
+	TriageFollowUp       = "follow-up"       // 待跟进
+)
+
+// triageLabels 审阅状态的显示名称
+var triageLabels = map[string]string{
+	TriageUnreviewed:     "未审阅",
+	TriageInteresting:    "有价值",
+	TriageNotInteresting: "无价值",
+	TriageFollowUp:       "待跟进",
+}
+
+// 标签和备注的长度限制
+const (
+	maxTriageTags    = 20
+	maxTriageTagLen  = 50
+	maxTriageNoteLen = 4000
+)
+
+// triageFile 审阅记录保存在运行目录中的文件名，与截图结果一起保留
+const triageFile = "triage.json"
+
+// Triage 截图结果的审阅记录：状态、自由标签和备注
+type Triage struct {
+	State     string    `json:"state"`
+	Tags      []string  `json:"tags,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+}
+
+// TriageUpdate 修改审阅记录的请求，未提供的字段保持不变
+type TriageUpdate struct {
+	State *string   `json:"state"`
+	Tags  *[]string `json:"tags"`
+	Note  *string   `json:"note"`
+}
+
+// StateLabel 返回审阅状态的显示名称
+func (t Triage) StateLabel() string {
+	return triageLabels[t.State]
+}
+
+// normalizeTags 去掉空白和重复（不区分大小写）的标签
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		if len([]rune(tag)) > maxTriageTagLen {
+			return nil, fmt.Errorf("标签 %q 超过 %d 个字符", tag, maxTriageTagLen)
+		}
+		seen[strings.ToLower(tag)] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTriageTags {
+		return nil, fmt.Errorf("标签不能超过 %d 个", maxTriageTags)
+	}
+	return normalized, nil
+}
+
+// triageStore 按运行保存审阅记录，首次访问某个运行时从运行目录读取
+type triageStore struct {
+	mu   sync.Mutex
+	runs map[string]map[string]Triage // 运行编号 -> 结果ID -> 审阅记录
+}
+
+var triages = &triageStore{runs: make(map[string]map[string]Triage)}
+
+// forRun 返回运行的审阅记录，调用方需持有 mu
+func (s *triageStore) forRun(runID string) map[string]Triage {
+	if records, ok := s.runs[runID]; ok {
+		return records
+	}
+	records := make(map[string]Triage)
+	if runIDPattern.MatchString(runID) {
+		data, err := os.ReadFile(filepath.Join(runsDir, runID, triageFile))
+		if err == nil {
+			if err := json.Unmarshal(data, &records); err != nil {
+				fmt.Printf("解析运行 %s 的审阅记录失败: %v\n", runID, err)
+			}
+		}
+	}
+	s.runs[runID] = records
+	return records
+}
+
+// get 返回结果的审阅记录，没有记录时为未审阅
+func (s *triageStore) get(resultID string) Triage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.forRun(resultRunID(resultID))[resultID]; ok {
+		return t
+	}
+	return Triage{State: TriageUnreviewed}
+}
+
+// update 校验并修改结果的审阅记录，写回运行目录
+func (s *triageStore) update(resultID string, u TriageUpdate, user string) (Triage, error) {
+	runID := resultRunID(resultID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := s.forRun(runID)
+	t, ok := records[resultID]
+	if !ok {
+		t.State = TriageUnreviewed
+	}
+	if u.State != nil {
+		if _, ok := triageLabels[*u.State]; !ok {
+			return Triage{}, fmt.Errorf("state 只支持 %s、%s、%s 或 %s", TriageUnreviewed, TriageInteresting, TriageNotInteresting, TriageFollowUp)
+		}
+		t.State = *u.State
+	}
+	if u.Tags != nil {
+		tags, err := normalizeTags(*u.Tags)
+		if err != nil {
+			return Triage{}, err
+		}
+		t.Tags = tags
+	}
+	if u.Note != nil {
+		note := strings.TrimSpace(*u.Note)
+		if len([]rune(note)) > maxTriageNoteLen {
+			return Triage{}, fmt.Errorf("备注不能超过 %d 个字符", maxTriageNoteLen)
+		}
+		t.Note = note
+	}
+	t.UpdatedAt = time.Now()
+	t.UpdatedBy = user
+
+	// 写入成功后才更新内存中的记录
+	updated := make(map[string]Triage, len(records)+1)
+	for id, record := range records {
+		updated[id] = record
+	}
+	updated[resultID] = t
+	data, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return Triage{}, err
+	}
+	// 先写临时文件再替换，避免写入中断时损坏已有记录
+	path := filepath.Join(runsDir, runID, triageFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return Triage{}, fmt.Errorf("保存审阅记录失败: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return Triage{}, fmt.Errorf("保存审阅记录失败: %v", err)
+	}
+	s.runs[runID] = updated
+	return t, nil
+}
+
+// withTriage 返回附带审阅记录的结果副本，用于接口返回和导出
+func withTriage(results []*CaptureResult) []*CaptureResult {
+	list := make([]*CaptureResult, len(results))
+	for i, result := range results {
+		copied := *result
+		t := triages.get(result.ID)
+		copied.Triage = &t
+		list[i] = &copied
+	}
+	return list
+}
+
+// resultFilter 按审阅状态、标签、分类和截图状态筛选结果，为空的条件不限制
+type resultFilter struct {
+	Triage   string
+	Tag      string
+	Category string
+	Status   string // ok（截图成功且无HTTP错误）、failed（没有截图）、http-error，或具体的HTTP状态码
+}
+
+// filterFromQuery 从查询参数 triage、tag、category、status 读取筛选条件
+func filterFromQuery(r *http.Request) (resultFilter, error) {
+	query := r.URL.Query()
+	f := resultFilter{
+		Triage:   query.Get("triage"),
+		Tag:      strings.TrimSpace(query.Get("tag")),
+		Category: query.Get("category"),
+		Status:   query.Get("status"),
+	}
+	if _, ok := triageLabels[f.Triage]; f.Triage != "" && !ok {
+		return f, fmt.Errorf("triage 只支持 %s、%s、%s 或 %s", TriageUnreviewed, TriageInteresting, TriageNotInteresting, TriageFollowUp)
+	}
+	switch f.Status {
+	case "", "ok", "failed", "http-error":
+	default:
+		if _, err := strconv.Atoi(f.Status); err != nil {
+			return f, fmt.Errorf("status 只支持 ok、failed、http-error 或HTTP状态码")
+		}
+	}
+	return f, nil
+}
+
+// active 是否设置了任何筛选条件
+func (f resultFilter) active() bool {
+	return f != resultFilter{}
+}
+
+// matches 判断结果（需已附带审阅记录）是否满足筛选条件
+func (f resultFilter) matches(result *CaptureResult) bool {
+	t := Triage{State: TriageUnreviewed}
+	if result.Triage != nil {
+		t = *result.Triage
+	}
+	if f.Triage != "" && t.State != f.Triage {
+		return false
+	}
+	if f.Tag != "" && !containsFold(t.Tags, f.Tag) {
+		return false
+	}
+	if f.Category != "" && result.Category != f.Category {
+		return false
+	}
+	switch f.Status {
+	case "":
+	case "ok":
+		return result.ErrorKind == ""
+	case "failed":
+		return result.ErrorKind != "" && result.ErrorKind != ErrorHTTPStatus
+	case "http-error":
+		return result.ErrorKind == ErrorHTTPStatus
+	default:
+		return strconv.FormatInt(result.StatusCode, 10) == f.Status
+	}
+	return true
+}
+
+// containsFold 列表中是否有与 s 相同（不区分大小写）的元素
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterResults 附带审阅记录并返回满足筛选条件的结果
+func filterResults(results []*CaptureResult, f resultFilter) []*CaptureResult {
+	results = withTriage(results)
+	if !f.active() {
+		return results
+	}
+	matched := make([]*CaptureResult, 0, len(results))
+	for _, result := range results {
+		if f.matches(result) {
+			matched = append(matched, result)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// WebSocket子协议：浏览器无法为WebSocket设置 Authorization 头，可在子协议列表中附带 bearer.<令牌>
+const (
+	wsProtocol       = "webcut"
+	wsBearerProtocol = "bearer."
+)
+
+// wsBearerToken 从WebSocket子协议中读取令牌
+func wsBearerToken(r *http.Request) (string, bool) {
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			if token, found := strings.CutPrefix(strings.TrimSpace(protocol), wsBearerProtocol); found {
+				return token, true
+			}
+		}
+	}
+	return "", false
+}
+
+// wsMessage 服务器发送的消息：type 为 event 时携带任务事件，为 reply 时是对控制命令的回复
+type wsMessage struct {
+	Type  string    `json:"type"`
+	Event *JobEvent `json:"event,omitempty"`
+	ID    string    `json:"id,omitempty"`
+	OK    bool      `json:"ok,omitempty"`
+	Error string    `json:"error,omitempty"`
+	Job   *Job      `json:"job,omitempty"`
+}
+
+// wsConn 多个协程共用的WebSocket连接，写入时加锁
+type wsConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func (c *wsConn) write(op ws.OpCode, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return wsutil.WriteServerMessage(c.conn, op, data)
+}
+
+func (c *wsConn) send(msg wsMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.write(ws.OpText, data)
+}
+
+// serveJobSocket 任务的双向控制通道：发送任务事件（与SSE相同，支持 lastEventId 补发），
+// 接收控制命令并回复执行结果，任务结束后关闭连接
+func serveJobSocket(w http.ResponseWriter, r *http.Request, job *Job) {
+	upgrader := ws.HTTPUpgrader{
+		Protocol: func(protocol string) bool { return protocol == wsProtocol },
+	}
+	conn, _, _, err := upgrader.Upgrade(r, w)
+	if err != nil {
+		fmt.Printf("WebSocket握手失败: %v\n", err)
+		return
+	}
+	defer conn.Close()
+	c := &wsConn{conn: conn}
+	user := requestUser(r)
+
+	// 读取控制命令，连接断开时通知事件循环退出
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			data, op, err := wsutil.ReadClientData(conn)
+			if err != nil {
+				return
+			}
+			if op != ws.OpText {
+				continue
+			}
+			var cmd JobCommand
+			reply := wsMessage{Type: "reply"}
+			if err := json.Unmarshal(data, &cmd); err != nil {
+				reply.Error = "命令格式错误: " + err.Error()
+			} else if err := job.control(cmd, user); err != nil {
+				reply.ID, reply.Error = cmd.ID, err.Error()
+			} else {
+				snapshot := job.snapshot()
+				reply.ID, reply.OK, reply.Job = cmd.ID, true, &snapshot
+			}
+			if c.send(reply) != nil {
+				return
+			}
+		}
+	}()
+
+	lastID := lastEventID(r)
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		events, notify, finished := job.eventsSince(lastID)
+		for i := range events {
+			if c.send(wsMessage{Type: "event", Event: &events[i]}) != nil {
+				return
+			}
+			lastID = events[i].ID
+		}
+		if finished {
+			c.write(ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, "任务已结束"))
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-keepAlive.C:
+			if c.write(ws.OpPing, nil) != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}